@@ -2,6 +2,7 @@ package build
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/mandelsoft/goutils/errors"
 	"github.com/mandelsoft/goutils/general"
@@ -87,7 +88,13 @@ func (e *Execution) Apply(elements ...addhdlrs.ElementSource) error {
 		return err
 	}
 
-	thdlr, err := standard.New(standard.KeepGlobalAccess(), standard.Recursive(), standard.ResourcesByValue())
+	elems, err = e.FilterExisting(repo, elems)
+	if err != nil {
+		repo.Close()
+		return err
+	}
+
+	thdlr, err := standard.New(standard.KeepGlobalAccess(), standard.Recursive(), standard.ResourcesByValue(), standard.Overwrite(e.opts.UpdateMode == UPDATE_REPLACE))
 	if err != nil {
 		return err
 	}
@@ -98,6 +105,9 @@ func (e *Execution) Apply(elements ...addhdlrs.ElementSource) error {
 		if err == nil {
 			err = cerr
 		}
+		if err == nil {
+			err = e.NormalizeArchive()
+		}
 	}
 	if err != nil {
 		if e.opts.Create {
@@ -109,6 +119,37 @@ func (e *Execution) Apply(elements ...addhdlrs.ElementSource) error {
 	return err
 }
 
+// FilterExisting applies the update mode to elements describing component
+// versions already present in the target repository.
+func (e *Execution) FilterExisting(repo ocm.Repository, elems []addhdlrs.Element) ([]addhdlrs.Element, error) {
+	if e.opts.UpdateMode == UPDATE_REPLACE {
+		return elems, nil
+	}
+
+	var result []addhdlrs.Element
+	list := errors.ErrListf("component versions already exist in %q", e.opts.Archive)
+	for _, elem := range elems {
+		spec := elem.Spec()
+		name := spec.GetName()
+		vers := general.OptionalDefaulted(e.opts.Version, spec.GetVersion())
+		ok, err := repo.ExistsComponentVersion(name, vers)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot check component version %s:%s", name, vers)
+		}
+		if ok {
+			switch e.opts.UpdateMode {
+			case UPDATE_FAIL:
+				list.Add(fmt.Errorf("%s:%s", name, vers))
+			case UPDATE_SKIP:
+				e.opts.Printer.Printf("skipping existing component version %s:%s\n", name, vers)
+			}
+			continue
+		}
+		result = append(result, elem)
+	}
+	return result, list.Result()
+}
+
 type ContentSource struct {
 	src  addhdlrs.SourceInfo
 	data []byte
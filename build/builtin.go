@@ -0,0 +1,90 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"ocm.software/ocm/api/utils/misc"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+	"github.com/mandelsoft/ocm-build/plugincache"
+	"github.com/mandelsoft/ocm-build/ppi"
+	"github.com/mandelsoft/ocm-build/state"
+)
+
+var (
+	builtinsLock sync.RWMutex
+	builtins     = map[string]ppi.Processor{}
+)
+
+// RegisterBuiltin registers a plugin executed in-process. It can be
+// used in a build file with builtin: <name>. Handlers are registered
+// with a plugin created by ppi.NewPlugin or ppi.NewGenericPlugin.
+func RegisterBuiltin(name string, p ppi.Processor) {
+	builtinsLock.Lock()
+	defer builtinsLock.Unlock()
+	builtins[name] = p
+}
+
+// GetBuiltin provides the builtin plugin with the given name or nil.
+func GetBuiltin(name string) ppi.Processor {
+	builtinsLock.RLock()
+	defer builtinsLock.RUnlock()
+	return builtins[name]
+}
+
+// Builtins provides the names of all registered builtin plugins.
+func Builtins() []string {
+	builtinsLock.RLock()
+	defer builtinsLock.RUnlock()
+	var names []string
+	for n := range builtins {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinPlugin provides the plugin for a builtin plugin specification.
+func BuiltinPlugin(pspec *buildfile.Plugin) (*plugincache.Plugin, error) {
+	if pspec.PluginRef != "" || pspec.Repository != nil || pspec.Component != "" || pspec.Version != "" || pspec.Resource != "" ||
+		pspec.Executable != nil || pspec.Local != nil || pspec.Source != nil {
+		return nil, fmt.Errorf("for a builtin plugin no other plugin specification is possible")
+	}
+	p := GetBuiltin(pspec.Builtin)
+	if p == nil {
+		return nil, fmt.Errorf("unknown builtin plugin %q", pspec.Builtin)
+	}
+	return plugincache.NewBuiltinPlugin(pspec.Builtin, p.Descriptor()), nil
+}
+
+// executeBuiltin executes a builtin plugin in-process. The handler works
+// on a copy of the actual state.
+func (e *Execution) executeBuiltin(printer misc.Printer, step string, p *plugincache.Plugin, index int, config json.RawMessage, env *state.Environment) (*state.Descriptor, error) {
+	b := GetBuiltin(p.Path())
+	if b == nil {
+		return nil, fmt.Errorf("unknown builtin plugin %q", p.Path())
+	}
+
+	data, err := json.Marshal(e.state)
+	if err != nil {
+		return nil, err
+	}
+	var pstate state.Descriptor
+	err = json.Unmarshal(data, &pstate)
+	if err != nil {
+		return nil, err
+	}
+
+	b.SetOutput(printer, func(ev *ppi.Event) error {
+		e.recordEvent(printer, step, ev)
+		return nil
+	})
+	err = b.Process(env, config, &pstate, index)
+	if err != nil {
+		return nil, err
+	}
+	return &pstate, nil
+}
@@ -2,7 +2,9 @@ package build
 
 import (
 	"os"
+	"strings"
 
+	"github.com/mandelsoft/filepath/pkg/filepath"
 	"github.com/mandelsoft/vfs/pkg/osfs"
 	"github.com/mandelsoft/vfs/pkg/vfs"
 	clictx "ocm.software/ocm/api/cli"
@@ -16,21 +18,43 @@ func Clean(ctx clictx.Context, opts Options, builds, cache bool) error {
 	return e.Clean(builds, cache)
 }
 
+// Clean removes the project state. The plugin cache is only removed,
+// if it is located in the build directory of the project. Other caches
+// may be shared with other projects and must be pruned explicitly.
 func (e *Execution) Clean(builds, cache bool) error {
 	var err error
 	if cache {
-		e.opts.Printer.Printf("cleaning plugin cache %s...\n", e.opts.PluginDir)
-		if ok, err := vfs.DirExists(osfs.OsFs, e.opts.PluginDir); ok && err == nil {
-			err = os.RemoveAll(e.opts.PluginDir)
+		if isSubDir(e.opts.BuildDir, e.opts.PluginDir) {
+			e.opts.Printer.Printf("cleaning plugin cache %s...\n", e.opts.PluginDir)
+			if ok, _ := vfs.DirExists(osfs.OsFs, e.opts.PluginDir); ok {
+				err = os.RemoveAll(e.opts.PluginDir)
+			}
+		} else {
+			e.opts.Printer.Printf("keeping shared plugin cache %s (use ocm-build-plugins prune)\n", e.opts.PluginDir)
 		}
 	}
 	if builds {
 		e.opts.Printer.Printf("cleaning archive %s...\n", e.opts.Archive)
 		os.RemoveAll(e.opts.Archive)
 		e.opts.Printer.Printf("cleaning generation dir %s...\n", e.opts.BuildDir)
-		if ok, err := vfs.DirExists(osfs.OsFs, e.opts.BuildDir); ok && err == nil {
-			err = os.RemoveAll(e.opts.BuildDir)
+		if ok, _ := vfs.DirExists(osfs.OsFs, e.opts.BuildDir); ok {
+			if rerr := os.RemoveAll(e.opts.BuildDir); rerr != nil {
+				err = rerr
+			}
 		}
 	}
 	return err
 }
+
+// isSubDir checks whether path is located in dir.
+func isSubDir(dir, path string) bool {
+	d, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	p, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return p == d || strings.HasPrefix(p, d+filepath.PathSeparatorString)
+}
@@ -0,0 +1,130 @@
+package build
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mandelsoft/goutils/errors"
+	"github.com/mandelsoft/goutils/maputils"
+	"github.com/mandelsoft/vfs/pkg/vfs"
+	"ocm.software/ocm/api/utils/misc"
+
+	"github.com/mandelsoft/ocm-build/ppi"
+)
+
+const REPORT_FILE = "report.json"
+
+// Report describes the build report written to the build directory.
+type Report struct {
+	Events []ReportEvent `json:"events,omitempty"`
+}
+
+// ReportEvent is a plugin event together with the build step
+// it has been emitted by.
+type ReportEvent struct {
+	Step      string `json:"step"`
+	ppi.Event `json:",inline"`
+}
+
+// EVENT_DRAIN_TIMEOUT is the time events are still read after
+// a plugin has finished.
+const EVENT_DRAIN_TIMEOUT = time.Second
+
+// runPlugin runs a plugin command. Events emitted by the plugin on
+// the event channel are rendered with the given printer and recorded
+// for the build report.
+func (e *Execution) runPlugin(cmd *exec.Cmd, printer misc.Printer, step string) error {
+	if runtime.GOOS == "windows" {
+		// no support for additional file descriptors
+		return cmd.Run()
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return errors.Wrapf(err, "cannot create event channel")
+	}
+	cmd.ExtraFiles = []*os.File{w}
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", ppi.ENV_EVENTS, 3))
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		r.Close()
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.readEvents(r, printer, step)
+	}()
+	err = cmd.Wait()
+	// processes started by the plugin may still hold the write end
+	// of the pipe, therefore only pending events are read.
+	r.SetReadDeadline(time.Now().Add(EVENT_DRAIN_TIMEOUT))
+	<-done
+	r.Close()
+	return err
+}
+
+func (e *Execution) readEvents(r io.Reader, printer misc.Printer, step string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev ppi.Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			printer.Printf("%s\n", scanner.Text())
+			continue
+		}
+		e.recordEvent(printer, step, &ev)
+	}
+}
+
+// recordEvent renders an event and records it for the build report.
+func (e *Execution) recordEvent(printer misc.Printer, step string, ev *ppi.Event) {
+	RenderEvent(printer, ev)
+	e.events = append(e.events, ReportEvent{Step: step, Event: *ev})
+}
+
+// RenderEvent prints an event.
+func RenderEvent(printer misc.Printer, ev *ppi.Event) {
+	msg := ev.Message
+	if len(ev.Fields) > 0 {
+		var fields []string
+		for _, k := range maputils.OrderedKeys(ev.Fields) {
+			fields = append(fields, fmt.Sprintf("%s=%v", k, ev.Fields[k]))
+		}
+		msg += " (" + strings.Join(fields, ", ") + ")"
+	}
+	switch ev.Kind {
+	case ppi.EVENT_WARNING:
+		printer.Printf("WARNING: %s\n", msg)
+	case ppi.EVENT_ERROR:
+		printer.Printf("ERROR: %s\n", msg)
+	default:
+		printer.Printf("%s\n", msg)
+	}
+}
+
+// WriteReport writes the build report into the build directory.
+func (e *Execution) WriteReport() error {
+	data, err := json.MarshalIndent(&Report{Events: e.events}, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = e.fs.MkdirAll(e.opts.BuildDir, 0o755)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create build dir")
+	}
+	path := vfs.Join(e.fs, e.opts.BuildDir, REPORT_FILE)
+	err = vfs.WriteFile(e.fs, path, data, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "cannot write build report %q", path)
+	}
+	return nil
+}
@@ -6,20 +6,30 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/mandelsoft/filepath/pkg/filepath"
 	"github.com/mandelsoft/goutils/errors"
+	"github.com/mandelsoft/goutils/maputils"
 	"github.com/mandelsoft/vfs/pkg/vfs"
 	clictx "ocm.software/ocm/api/cli"
 	"ocm.software/ocm/api/datacontext/attrs/vfsattr"
+	"ocm.software/ocm/api/ocm/extensions/repositories/ctf"
+	"ocm.software/ocm/api/utils/accessio"
+	"ocm.software/ocm/api/utils/accessobj"
 	"ocm.software/ocm/api/utils/misc"
 	"ocm.software/ocm/api/utils/runtime"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/rscs"
 
 	"github.com/mandelsoft/ocm-build/buildfile"
 	"github.com/mandelsoft/ocm-build/plugincache"
+	"github.com/mandelsoft/ocm-build/ppi"
 	"github.com/mandelsoft/ocm-build/state"
+	"github.com/mandelsoft/ocm-build/utils"
 )
 
 type Execution struct {
@@ -31,6 +41,14 @@ type Execution struct {
 	dir       string
 	buildfile *buildfile.Descriptor
 	state     *state.Descriptor
+	producers map[string]string
+
+	git         *utils.GitInfo
+	gitResolved bool
+	info        *state.BuildInfo
+
+	events  []ReportEvent
+	servers map[string]*pluginServer
 }
 
 func New(ctx clictx.Context, opts Options) (*Execution, error) {
@@ -39,14 +57,74 @@ func New(ctx clictx.Context, opts Options) (*Execution, error) {
 		return nil, err
 	}
 
-	plugins, err := plugincache.New(ctx.OCMContext(), opts.PluginDir, opts.Printer)
+	fs := vfsattr.Get(ctx)
+	dir := vfs.Dir(fs, opts.BuildFile)
+
+	bd, err := LoadBuildFile(fs, &opts)
 	if err != nil {
 		return nil, err
 	}
 
-	fs := vfsattr.Get(ctx)
-	dir := vfs.Dir(fs, opts.BuildFile)
+	lock, err := plugincache.ReadLock(fs, opts.LockFile)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Locked && lock == nil {
+		return nil, errors.Newf("lock file %q not found (resolve required)", opts.LockFile)
+	}
+
+	popts := &plugincache.Options{
+		AllowUnsigned:   opts.AllowUnsigned,
+		AllowUnverified: opts.AllowUnverified,
+		Lock:            lock,
+		Locked:          opts.Locked,
+		Offline:         opts.Offline,
+		Mirrors:         bd.Mirrors,
+	}
+	if bd.Trust != nil {
+		popts.Signatures = bd.Trust.Signatures
+		popts.PublicKeys = map[string][]byte{}
+		base := &utils.BasePath{Directory: dir}
+		for n, f := range bd.Trust.PublicKeys {
+			key, err := vfs.ReadFile(fs, base.Path(f))
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot read public key for signature %q", n)
+			}
+			popts.PublicKeys[n] = key
+		}
+	}
 
+	for _, b := range opts.Bundles {
+		spec, err := ctf.NewRepositorySpec(accessobj.ACC_READONLY, b, accessio.PathFileSystem(fs))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid plugin bundle %q", b)
+		}
+		popts.Fallbacks = append(popts.Fallbacks, spec)
+	}
+
+	plugins, err := plugincache.New(ctx.OCMContext(), opts.PluginDir, opts.Printer, popts)
+	if err != nil {
+		return nil, err
+	}
+
+	pstate := state.New(bd)
+
+	execution := &Execution{
+		ctx:       ctx,
+		opts:      &opts,
+		plugins:   plugins,
+		fs:        fs,
+		dir:       dir,
+		buildfile: bd,
+		state:     pstate,
+		producers: map[string]string{},
+	}
+	return execution, nil
+}
+
+// LoadBuildFile reads and decodes the build file described by
+// the (completed) options.
+func LoadBuildFile(fs vfs.FileSystem, opts *Options) (*buildfile.Descriptor, error) {
 	data, err := vfs.ReadFile(fs, opts.BuildFile)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot read build file")
@@ -67,19 +145,11 @@ func New(ctx clictx.Context, opts Options) (*Execution, error) {
 	if bd.Version == "" {
 		bd.Version = opts.Version
 	}
-
-	pstate := state.New(&bd)
-
-	execution := &Execution{
-		ctx:       ctx,
-		opts:      &opts,
-		plugins:   plugins,
-		fs:        fs,
-		dir:       dir,
-		buildfile: &bd,
-		state:     pstate,
+	err = bd.ResolveAliases()
+	if err != nil {
+		return nil, err
 	}
-	return execution, nil
+	return &bd, nil
 }
 
 func Execute(ctx clictx.Context, opts Options) error {
@@ -90,47 +160,96 @@ func Execute(ctx clictx.Context, opts Options) error {
 	return e.Run()
 }
 
+// Run executes the build and writes the build report.
 func (e *Execution) Run() error {
+	err := e.run()
+	if serr := e.StopServers(); serr != nil && err == nil {
+		err = serr
+	}
+	if rerr := e.WriteReport(); rerr != nil && err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (e *Execution) run() error {
 	printer := e.opts.Printer
 	printer.Printf("executing build...\n")
 	printer = printer.AddGap("  ")
 
+	if e.opts.Offline {
+		err := e.CheckOffline()
+		if err != nil {
+			return err
+		}
+	}
+	err := e.Validate()
+	if err != nil {
+		return err
+	}
+
 	if len(e.buildfile.Builds) > 0 {
 		printer.Printf("executing build steps...\n")
-		err := e.ExecuteBuilds(printer.AddGap("  "), e.buildfile.Builds, -1, "")
+		err = e.ExecuteBuilds(printer.AddGap("  "), e.buildfile.Builds, -1, "")
 		if err != nil {
 			return err
 		}
 	}
-	if len(e.buildfile.Components) > 0 {
+	var components []buildfile.Component
+	for _, c := range e.buildfile.Components {
+		if e.selected(&c) {
+			components = append(components, c)
+		}
+	}
+	static := len(components)
+	generated, err := e.generated()
+	if err != nil {
+		return err
+	}
+	components = append(components, generated...)
+
+	if len(components) > 0 {
 		printer.Printf("executing component build steps...\n")
 		printer := printer.AddGap("  ")
-		n := 0
-		for _, c := range e.buildfile.Components {
-			if len(e.opts.Components) > 0 {
-				found := false
-				for _, t := range e.opts.Components {
-					i := strings.Index(t, ":")
-					if i < 0 {
-						found = t == c.Name
-					} else {
-						found = t[:i] == c.Name && (t[i+1:] == c.Version || (c.Version == "" && t[i+1:] == e.state.BuildFile.Version))
-					}
-					if found {
-						break
-					}
-				}
-				if !found {
-					continue
-				}
+		for n := 0; n < len(components); n++ {
+			c := components[n]
+			var info []*state.BuildInfo
+			if s := e.buildfile.BuildInfo.Merge(c.BuildInfo); s.AddSource() || s.AddLabels() {
+				info = append(info, e.buildInfo())
 			}
-			res := e.state.AddComponent(&c)
-			printer.Printf("building component %s...\n", misc.VersionedElementKey(res))
-			err := e.ExecuteBuilds(printer.AddGap("  "), c.Builds, n, fmt.Sprintf("component %s, ", misc.VersionedElementKey(res)))
+			res, err := e.state.AddComponent(&c, info...)
+			if err != nil {
+				return errors.Wrapf(err, "component %s", c.Name)
+			}
+			ectx := fmt.Sprintf("component %s, ", misc.VersionedElementKey(res))
+			if n >= static {
+				printer.Printf("building generated component %s...\n", misc.VersionedElementKey(res))
+				err = e.ValidateBuilds(c.Builds, n, ectx)
+			} else {
+				printer.Printf("building component %s...\n", misc.VersionedElementKey(res))
+			}
+			if err == nil {
+				err = e.ExecuteBuilds(printer.AddGap("  "), c.Builds, n, ectx)
+			}
+			if err != nil {
+				return err
+			}
+			generated, err := e.generated()
 			if err != nil {
 				return err
 			}
-			n++
+			components = append(components, generated...)
+		}
+	}
+
+	if len(e.buildfile.PostBuilds) > 0 {
+		printer.Printf("executing post build steps...\n")
+		err = e.ExecuteBuilds(printer.AddGap("  "), e.buildfile.PostBuilds, -1, "post build ")
+		if err != nil {
+			return err
+		}
+		if len(e.state.Generated) > 0 {
+			return errors.Newf("post build steps cannot generate components")
 		}
 	}
 
@@ -147,9 +266,79 @@ func (e *Execution) Run() error {
 	}
 }
 
+// generated takes the components requested by generator plugins from
+// the build state. Plugin aliases used by their build steps are resolved.
+func (e *Execution) generated() ([]buildfile.Component, error) {
+	var result []buildfile.Component
+	for _, c := range e.state.Generated {
+		if !e.selected(&c) {
+			continue
+		}
+		c.Builds = append([]buildfile.Build{}, c.Builds...)
+		for i := range c.Builds {
+			err := e.buildfile.ResolveAlias(&c.Builds[i])
+			if err != nil {
+				return nil, errors.Wrapf(err, "generated component %s, step %d", c.Name, i+1)
+			}
+		}
+		result = append(result, c)
+	}
+	e.state.Generated = nil
+	return result, nil
+}
+
+// selected checks whether a component is selected for the build.
+func (e *Execution) selected(c *buildfile.Component) bool {
+	if len(e.opts.Components) == 0 {
+		return true
+	}
+	for _, t := range e.opts.Components {
+		i := strings.Index(t, ":")
+		if i < 0 {
+			if t == c.Name {
+				return true
+			}
+		} else {
+			if t[:i] == c.Name && (t[i+1:] == c.Version || (c.Version == "" && t[i+1:] == e.state.BuildFile.Version)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckOffline checks whether all plugins required for the selected
+// components are available without accessing any repository.
+func (e *Execution) CheckOffline() error {
+	list := errors.ErrListf("plugins not available offline")
+	check := func(builds []buildfile.Build, ectx string) {
+		for i, b := range builds {
+			if b.Local != nil {
+				// provided by the build itself
+				continue
+			}
+			_, err := e.Plugin(&b.Plugin)
+			if err != nil {
+				list.Add(errors.Wrapf(err, "%sstep %d", ectx, i+1))
+			}
+		}
+	}
+	check(e.buildfile.Builds, "")
+	for _, c := range e.buildfile.Components {
+		if e.selected(&c) {
+			check(c.Builds, fmt.Sprintf("component %s, ", c.Name))
+		}
+	}
+	check(e.buildfile.PostBuilds, "post build ")
+	return list.Result()
+}
+
 func (e *Execution) ExecuteBuilds(printer misc.Printer, builds []buildfile.Build, n int, ectx string) error {
 	for i, b := range builds {
-		p, err := e.plugins.Get(&b.Plugin, e.dir)
+		p, err := e.Plugin(&b.Plugin)
+		if err == nil && b.Local != nil {
+			err = e.ValidateStep(p, &b, n)
+		}
 		if err != nil {
 			return errors.Wrapf(err, "%sstep %d", ectx, i+1)
 		}
@@ -157,17 +346,107 @@ func (e *Execution) ExecuteBuilds(printer misc.Printer, builds []buildfile.Build
 		gendir := vfs.Join(e.fs, e.opts.BuildDir, "steps", hex.EncodeToString(hash[:]))
 		env := state.NewEnvironment(e.dir, gendir)
 		printer.Printf("step %d[%s] in %s...\n", i+1, p.String(), gendir)
+		step := fmt.Sprintf("%sstep %d[%s]", ectx, i+1, p.String())
 
-		nstate, err := e.ExecutePlugin(p, n, b.Config, env)
+		start := time.Now()
+		nstate, err := e.ExecutePlugin(printer.AddGap("  "), step, p, n, b.Config, env)
 		if err != nil {
 			return errors.Wrapf(err, "%sstep %d", ectx, i+1)
 		}
+		end := time.Now()
 		e.state = nstate
+		added := e.recordProducers(step)
+		if e.opts.Provenance && len(added) > 0 {
+			err = AddProvenance(NewProvenance(step, p, b.Config, e.gitInfo(), start, end), added...)
+			if err != nil {
+				return errors.Wrapf(err, "%sstep %d: cannot add provenance", ectx, i+1)
+			}
+		}
 	}
 	return nil
 }
 
-func (e *Execution) ExecutePlugin(p *plugincache.Plugin, index int, config json.RawMessage, env *state.Environment) (*state.Descriptor, error) {
+// recordProducers remembers the build step for all yet unknown resources
+// and returns those resources.
+func (e *Execution) recordProducers(step string) []*rscs.ResourceSpec {
+	var added []*rscs.ResourceSpec
+	for _, c := range e.state.Components {
+		for _, r := range c.Resources {
+			key := ResourceKey(c.Name, c.Version, r.Name, r.ExtraIdentity)
+			if _, ok := e.producers[key]; !ok {
+				e.producers[key] = step
+				added = append(added, r)
+			}
+		}
+	}
+	return added
+}
+
+func (e *Execution) buildInfo() *state.BuildInfo {
+	if e.info == nil {
+		host, _ := os.Hostname()
+		t, err := utils.SourceDateEpoch(time.Now())
+		if err != nil {
+			t = time.Now()
+		}
+		e.info = &state.BuildInfo{
+			Git:  e.gitInfo(),
+			Time: t,
+			Host: host,
+		}
+	}
+	return e.info
+}
+
+func (e *Execution) gitInfo() *utils.GitInfo {
+	if !e.gitResolved {
+		info, err := utils.GetGitInfo(e.dir)
+		if err != nil {
+			e.opts.Printer.Printf("WARNING: no git information: %s\n", err)
+		}
+		e.git = info
+		e.gitResolved = true
+	}
+	return e.git
+}
+
+// Producer provides the build step which created the described resource.
+func (e *Execution) Producer(comp, vers, name string, extra map[string]string) string {
+	return e.producers[ResourceKey(comp, vers, name, extra)]
+}
+
+func ResourceKey(comp, vers, name string, extra map[string]string) string {
+	keys := maputils.OrderedKeys(extra)
+	key := fmt.Sprintf("%s:%s/%s", comp, vers, name)
+	for _, k := range keys {
+		key += fmt.Sprintf(",%s=%s", k, extra[k])
+	}
+	return key
+}
+
+// ExecutePlugin executes a plugin for a build step. The protocol version is
+// negotiated using the plugin descriptor. Plugins without descriptor use
+// protocol version v1. Plugins supporting the server mode are started once
+// and used for all steps using the same plugin. Builtin plugins are executed
+// in-process. Plugin events are rendered with the given printer.
+func (e *Execution) ExecutePlugin(printer misc.Printer, step string, p *plugincache.Plugin, index int, config json.RawMessage, env *state.Environment) (*state.Descriptor, error) {
+	if p.IsBuiltin() {
+		return e.executeBuiltin(printer, step, p, index, config, env)
+	}
+	d, err := e.plugins.Describe(p)
+	if err != nil {
+		return nil, err
+	}
+	if d != nil && d.Server && !e.opts.NoPluginServer && d.SupportsProtocol(ppi.PROTOCOL_V2) {
+		return e.executePluginServer(printer, step, p, index, config, env)
+	}
+	if d != nil && d.SupportsProtocol(ppi.PROTOCOL_V2) {
+		return e.executePluginV2(printer, step, p, index, config, env)
+	}
+	return e.executePluginV1(printer, step, p, index, config, env)
+}
+
+func (e *Execution) executePluginV1(printer misc.Printer, step string, p *plugincache.Plugin, index int, config json.RawMessage, env *state.Environment) (*state.Descriptor, error) {
 	envdata, err := json.Marshal(env)
 	if err != nil {
 		return nil, err
@@ -186,7 +465,7 @@ func (e *Execution) ExecutePlugin(p *plugincache.Plugin, index int, config json.
 	cmd.Stdout = out
 	cmd.Stderr = e.ctx.StdOut()
 
-	err = cmd.Run()
+	err = e.runPlugin(cmd, printer, step)
 	if err != nil {
 		return nil, err
 	}
@@ -198,3 +477,53 @@ func (e *Execution) ExecutePlugin(p *plugincache.Plugin, index int, config json.
 	}
 	return &result, nil
 }
+
+// executePluginV2 exchanges the state with request and response files.
+func (e *Execution) executePluginV2(printer misc.Printer, step string, p *plugincache.Plugin, index int, config json.RawMessage, env *state.Environment) (*state.Descriptor, error) {
+	dir, err := os.MkdirTemp("", "ocm-build-*")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := json.Marshal(&ppi.Request{
+		Protocol:    ppi.PROTOCOL_V2,
+		Environment: *env,
+		Index:       index,
+		Config:      config,
+		State:       e.state,
+	})
+	if err != nil {
+		return nil, err
+	}
+	request := filepath.Join(dir, "request.json")
+	response := filepath.Join(dir, "response.json")
+	err = os.WriteFile(request, data, 0o600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot write plugin request")
+	}
+
+	cmd := exec.Command(p.Path(), p.Args(ppi.OPT_REQUEST, request, ppi.OPT_RESPONSE, response)...)
+	cmd.Stdout = e.ctx.StdOut()
+	cmd.Stderr = e.ctx.StdOut()
+	err = e.runPlugin(cmd, printer, step)
+
+	var result ppi.Response
+	if data, rerr := os.ReadFile(response); rerr == nil {
+		if uerr := json.Unmarshal(data, &result); uerr != nil && err == nil {
+			err = errors.Wrapf(uerr, "cannot unmarshal plugin response")
+		}
+	} else if err == nil {
+		err = errors.Wrapf(rerr, "cannot read plugin response")
+	}
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.State == nil {
+		return nil, errors.Newf("no state in plugin response")
+	}
+	return result.State, nil
+}
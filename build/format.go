@@ -0,0 +1,180 @@
+package build
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mandelsoft/goutils/errors"
+	"github.com/mandelsoft/vfs/pkg/vfs"
+	"ocm.software/ocm/api/utils/accessio"
+
+	"github.com/mandelsoft/ocm-build/utils"
+)
+
+const ENV_SOURCE_DATE_EPOCH = utils.ENV_SOURCE_DATE_EPOCH
+
+// NormalizeArchive rewrites a tar based transport archive according to
+// the configured compression level and reproducibility settings.
+func (e *Execution) NormalizeArchive() error {
+	format := e.opts.Format.Format()
+	if format != accessio.FormatTar && format != accessio.FormatTGZ {
+		return nil
+	}
+	compressed := format == accessio.FormatTGZ
+	if !e.opts.Reproducible && (!compressed || e.opts.Compression == 0) {
+		return nil
+	}
+	return NormalizeArchive(e.fs, e.opts.Archive, compressed, e.opts.Compression, e.opts.Reproducible)
+}
+
+// NormalizeArchive rewrites the given tar archive. A compression level of 0
+// uses the default gzip compression. In reproducible mode the entries are
+// sorted by name and owners, file modes and timestamps are normalized.
+// The timestamp is taken from SOURCE_DATE_EPOCH, if set.
+func NormalizeArchive(fs vfs.FileSystem, path string, compressed bool, level int, reproducible bool) (err error) {
+	defer errors.PropagateErrorf(&err, nil, "cannot normalize archive %q", path)
+
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	mtime, err := SourceDateEpoch()
+	if err != nil {
+		return err
+	}
+
+	src, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var in io.Reader = src
+	if compressed {
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		in = zr
+	}
+
+	spool, err := os.CreateTemp("", "archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	type entry struct {
+		header *tar.Header
+		offset int64
+	}
+
+	var entries []entry
+	var offset int64
+	tr := tar.NewReader(in)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(spool, tr)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{header: h, offset: offset})
+		offset += n
+	}
+
+	if reproducible {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].header.Name < entries[j].header.Name })
+	}
+
+	dst, err := vfs.TempFile(fs, vfs.Dir(fs, path), ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer fs.Remove(dst.Name())
+
+	var out io.Writer = dst
+	var zw *gzip.Writer
+	if compressed {
+		zw, err = gzip.NewWriterLevel(dst, level)
+		if err != nil {
+			dst.Close()
+			return err
+		}
+		out = zw
+	}
+	tw := tar.NewWriter(out)
+	for _, e := range entries {
+		h := e.header
+		if reproducible {
+			h = normalizeHeader(h, mtime)
+		}
+		err = tw.WriteHeader(h)
+		if err == nil {
+			_, err = io.Copy(tw, io.NewSectionReader(spool, e.offset, e.header.Size))
+		}
+		if err != nil {
+			dst.Close()
+			return err
+		}
+	}
+	err = tw.Close()
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	src.Close()
+	return replaceFile(fs, dst.Name(), path)
+}
+
+// replaceFile renames a file to an existing target. Not all filesystems
+// replace the target on rename, therefore it is removed on failure.
+func replaceFile(fs vfs.FileSystem, src, target string) error {
+	err := fs.Rename(src, target)
+	if err == nil {
+		return nil
+	}
+	if rerr := fs.Remove(target); rerr != nil {
+		return err
+	}
+	return fs.Rename(src, target)
+}
+
+func normalizeHeader(h *tar.Header, mtime time.Time) *tar.Header {
+	n := *h
+	n.Uid = 0
+	n.Gid = 0
+	n.Uname = ""
+	n.Gname = ""
+	n.ModTime = mtime
+	n.AccessTime = time.Time{}
+	n.ChangeTime = time.Time{}
+	n.PAXRecords = nil
+	n.Format = tar.FormatUnknown
+	if n.Typeflag == tar.TypeDir || n.Mode&0o111 != 0 {
+		n.Mode = 0o755
+	} else {
+		n.Mode = 0o644
+	}
+	return &n
+}
+
+// SourceDateEpoch provides the timestamp used for reproducible archives.
+func SourceDateEpoch() (time.Time, error) {
+	return utils.SourceDateEpoch(time.Unix(0, 0))
+}
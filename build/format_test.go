@@ -0,0 +1,242 @@
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mandelsoft/vfs/pkg/memoryfs"
+	"github.com/mandelsoft/vfs/pkg/vfs"
+)
+
+func TestNormalizeHeader(t *testing.T) {
+	mtime := time.Unix(1700000000, 0).UTC()
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		header tar.Header
+		mode   int64
+	}{
+		{"regular file", tar.Header{Typeflag: tar.TypeReg, Name: "a", Mode: 0o600}, 0o644},
+		{"executable", tar.Header{Typeflag: tar.TypeReg, Name: "b", Mode: 0o700}, 0o755},
+		{"group executable", tar.Header{Typeflag: tar.TypeReg, Name: "b", Mode: 0o610}, 0o755},
+		{"directory", tar.Header{Typeflag: tar.TypeDir, Name: "d/", Mode: 0o700}, 0o755},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := tc.header
+			h.Uid, h.Gid, h.Uname, h.Gname = 1000, 1000, "user", "group"
+			h.ModTime, h.AccessTime, h.ChangeTime = now, now, now
+			h.PAXRecords = map[string]string{"mtime": "1"}
+			h.Format = tar.FormatPAX
+
+			n := normalizeHeader(&h, mtime)
+			if n == &h {
+				t.Fatalf("header must be copied")
+			}
+			if n.Mode != tc.mode {
+				t.Errorf("mode: expected %o, found %o", tc.mode, n.Mode)
+			}
+			if n.Uid != 0 || n.Gid != 0 || n.Uname != "" || n.Gname != "" {
+				t.Errorf("owner not normalized: %d/%d %q/%q", n.Uid, n.Gid, n.Uname, n.Gname)
+			}
+			if !n.ModTime.Equal(mtime) || !n.AccessTime.IsZero() || !n.ChangeTime.IsZero() {
+				t.Errorf("timestamps not normalized: %s %s %s", n.ModTime, n.AccessTime, n.ChangeTime)
+			}
+			if n.PAXRecords != nil || n.Format != tar.FormatUnknown {
+				t.Errorf("format not normalized")
+			}
+			if n.Name != tc.header.Name || n.Typeflag != tc.header.Typeflag {
+				t.Errorf("name or type modified")
+			}
+			if h.Uid != 1000 {
+				t.Errorf("original header modified")
+			}
+		})
+	}
+}
+
+type testEntry struct {
+	name    string
+	mode    int64
+	content string
+}
+
+func writeTestArchive(t *testing.T, fs vfs.FileSystem, path string, compressed bool, mtime time.Time, entries ...testEntry) {
+	buf := bytes.NewBuffer(nil)
+	var out io.Writer = buf
+	var zw *gzip.Writer
+	if compressed {
+		zw = gzip.NewWriter(buf)
+		out = zw
+	}
+	tw := tar.NewWriter(out)
+	for _, e := range entries {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     e.name,
+			Mode:     e.mode,
+			Size:     int64(len(e.content)),
+			Uid:      4711,
+			Uname:    "builder",
+			ModTime:  mtime,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte(e.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := vfs.WriteFile(fs, path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestArchive(t *testing.T, fs vfs.FileSystem, path string, compressed bool) ([]*tar.Header, []string) {
+	f, err := fs.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var in io.Reader = f
+	if compressed {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in = zr
+	}
+	var headers []*tar.Header
+	var contents []string
+	tr := tar.NewReader(in)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers = append(headers, h)
+		contents = append(contents, string(data))
+	}
+	return headers, contents
+}
+
+func TestNormalizeArchive(t *testing.T) {
+	entries := []testEntry{
+		{"z/blob", 0o600, "blob content"},
+		{"a/index.json", 0o640, "{}"},
+		{"m/exec", 0o750, "#!/bin/sh"},
+	}
+
+	tests := []struct {
+		name         string
+		compressed   bool
+		reproducible bool
+		epoch        string
+		names        []string
+	}{
+		{"tar keeps order", false, false, "", []string{"z/blob", "a/index.json", "m/exec"}},
+		{"tar reproducible", false, true, "", []string{"a/index.json", "m/exec", "z/blob"}},
+		{"tgz reproducible", true, true, "1700000000", []string{"a/index.json", "m/exec", "z/blob"}},
+		{"tgz recompressed", true, false, "", []string{"z/blob", "a/index.json", "m/exec"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(ENV_SOURCE_DATE_EPOCH, tc.epoch)
+			fs := memoryfs.New()
+			orig := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			writeTestArchive(t, fs, "/archive", tc.compressed, orig, entries...)
+
+			err := NormalizeArchive(fs, "/archive", tc.compressed, gzip.BestCompression, tc.reproducible)
+			if err != nil {
+				t.Fatal(err)
+			}
+			headers, contents := readTestArchive(t, fs, "/archive", tc.compressed)
+			if len(headers) != len(tc.names) {
+				t.Fatalf("expected %d entries, found %d", len(tc.names), len(headers))
+			}
+			expected, _ := SourceDateEpoch()
+			for i, h := range headers {
+				if h.Name != tc.names[i] {
+					t.Errorf("entry %d: expected %q, found %q", i, tc.names[i], h.Name)
+				}
+				for _, e := range entries {
+					if e.name == h.Name && e.content != contents[i] {
+						t.Errorf("entry %q: content mismatch", h.Name)
+					}
+				}
+				if tc.reproducible {
+					if !h.ModTime.Equal(expected) || h.Uid != 0 || h.Uname != "" {
+						t.Errorf("entry %q not normalized", h.Name)
+					}
+				} else if !h.ModTime.Equal(orig) || h.Uid != 4711 {
+					t.Errorf("entry %q modified", h.Name)
+				}
+			}
+			if files, _ := vfs.ReadDir(fs, "/"); len(files) != 1 {
+				t.Errorf("temporary files left: %d entries", len(files))
+			}
+		})
+	}
+}
+
+func TestNormalizeArchiveIsStable(t *testing.T) {
+	t.Setenv(ENV_SOURCE_DATE_EPOCH, "")
+	fs := memoryfs.New()
+	writeTestArchive(t, fs, "/a", true, time.Now(), testEntry{"b", 0o644, "b"}, testEntry{"a", 0o600, "a"})
+	writeTestArchive(t, fs, "/b", true, time.Now().Add(time.Hour), testEntry{"a", 0o644, "a"}, testEntry{"b", 0o640, "b"})
+	for _, p := range []string{"/a", "/b"} {
+		if err := NormalizeArchive(fs, p, true, 0, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, _ := vfs.ReadFile(fs, "/a")
+	b, _ := vfs.ReadFile(fs, "/b")
+	if !bytes.Equal(a, b) {
+		t.Errorf("reproducible archives differ")
+	}
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+		fail     bool
+	}{
+		{"", time.Unix(0, 0).UTC(), false},
+		{"1700000000", time.Unix(1700000000, 0).UTC(), false},
+		{"invalid", time.Time{}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(ENV_SOURCE_DATE_EPOCH, tc.value)
+			tm, err := SourceDateEpoch()
+			if (err != nil) != tc.fail {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if !tm.Equal(tc.expected) {
+				t.Errorf("expected %s, found %s", tc.expected, tm)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package build
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/mandelsoft/vfs/pkg/vfs"
+	"ocm.software/ocm/api/ocm/extraid"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+	"github.com/mandelsoft/ocm-build/plugincache"
+	"github.com/mandelsoft/ocm-build/state"
+	"github.com/mandelsoft/ocm-build/utils"
+)
+
+// Plugin provides the plugin for a plugin specification. Local plugins
+// are taken from the current build state, builtin plugins from the
+// builtin registry and all others are provided by the plugin cache.
+func (e *Execution) Plugin(pspec *buildfile.Plugin) (*plugincache.Plugin, error) {
+	if pspec.Builtin != "" {
+		return BuiltinPlugin(pspec)
+	}
+	if pspec.Local != nil {
+		return e.LocalPlugin(pspec.Local)
+	}
+	return e.plugins.Get(pspec, e.dir)
+}
+
+// LocalPlugin provides a build plugin resource added to a component
+// by a previous build step for the actual platform.
+func (e *Execution) LocalPlugin(spec *buildfile.LocalPlugin) (*plugincache.Plugin, error) {
+	if spec.Component == "" || spec.Resource == "" {
+		return nil, fmt.Errorf("component and resource required for local plugin")
+	}
+	comp := false
+	for _, c := range e.state.Components {
+		if c.Name != spec.Component {
+			continue
+		}
+		comp = true
+		for _, r := range c.Resources {
+			if r.Name != spec.Resource {
+				continue
+			}
+			if r.Type != plugincache.RESOURCE_TYPE {
+				return nil, fmt.Errorf("resource %q of component %s has wrong type: %s", r.Name, c.Name, r.Type)
+			}
+			goos := r.ExtraIdentity[extraid.ExecutableOperatingSystem]
+			arch := r.ExtraIdentity[extraid.ExecutableArchitecture]
+			if (goos != "" && goos != runtime.GOOS) || (arch != "" && arch != runtime.GOARCH) {
+				continue
+			}
+			path, ok := state.FileInput(r)
+			if !ok {
+				return nil, fmt.Errorf("resource %q of component %s is not described by a file", r.Name, c.Name)
+			}
+			path = (&utils.BasePath{Directory: e.dir}).Path(path)
+			if ok, err := vfs.FileExists(e.fs, path); !ok || err != nil {
+				return nil, fmt.Errorf("executable %q for resource %q of component %s not found", path, r.Name, c.Name)
+			}
+			return plugincache.NewPlugin(path, fmt.Sprintf("local %s[%s]", c.Name, r.Name)), nil
+		}
+	}
+	if !comp {
+		return nil, fmt.Errorf("component %s not yet built", spec.Component)
+	}
+	return nil, fmt.Errorf("os %s architecture %s not found for resource %q of component %s", runtime.GOOS, runtime.GOARCH, spec.Resource, spec.Component)
+}
@@ -1,22 +1,50 @@
 package build
 
 import (
+	"os"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+	"github.com/mandelsoft/goutils/errors"
 	"github.com/mandelsoft/vfs/pkg/vfs"
 	clictx "ocm.software/ocm/api/cli"
 	"ocm.software/ocm/api/ocm/extensions/repositories/ctf"
 	"ocm.software/ocm/api/utils/misc"
 	"ocm.software/ocm/api/utils/template"
+
+	"github.com/mandelsoft/ocm-build/plugincache"
+)
+
+const (
+	UPDATE_REPLACE = "replace"
+	UPDATE_SKIP    = "skip"
+	UPDATE_FAIL    = "fail"
 )
 
 type Options struct {
-	Create    bool
-	Force     bool
-	ReResolve bool
+	Create        bool
+	Force         bool
+	ReResolve     bool
+	AllowUnsigned bool
+	// AllowUnverified accepts plugins whose content cannot be verified.
+	AllowUnverified bool
+	Locked          bool
+	Offline         bool
+	// NoPluginServer disables the server mode of plugins.
+	NoPluginServer bool
 
 	Archive   string
 	Format    ctf.FormatHandler
 	Mode      vfs.FileMode
 	BuildFile string
+	LockFile  string
+	// Bundles are plugin bundles used as fallback repositories
+	// for plugin resolution.
+	Bundles []string
+
+	Compression  int
+	Reproducible bool
+	UpdateMode   string
+	Provenance   bool
 
 	Version string
 
@@ -30,6 +58,17 @@ type Options struct {
 	Components []string
 }
 
+// DefaultPluginDir provides the user-wide plugin cache
+// ($XDG_CACHE_HOME/ocm-build/plugins on Linux). Because cache entries are
+// content-addressed, it is shared among all projects.
+func DefaultPluginDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ocm-build", "plugins")
+}
+
 func (o *Options) Complete(ctx clictx.Context) error {
 	if o.Version == "" {
 		o.Version = "0.1.0"
@@ -37,6 +76,9 @@ func (o *Options) Complete(ctx clictx.Context) error {
 	if o.BuildFile == "" {
 		o.BuildFile = "BuildFile.yaml"
 	}
+	if o.LockFile == "" {
+		o.LockFile = plugincache.LockFile(o.BuildFile)
+	}
 	if o.Format == nil {
 		o.Format = ctf.FormatDirectory
 	}
@@ -46,6 +88,16 @@ func (o *Options) Complete(ctx clictx.Context) error {
 	if o.Format == ctf.FormatDirectory {
 		o.Mode |= 0o100
 	}
+	switch o.UpdateMode {
+	case "":
+		o.UpdateMode = UPDATE_REPLACE
+	case UPDATE_REPLACE, UPDATE_SKIP, UPDATE_FAIL:
+	default:
+		return errors.Newf("invalid update mode %q (use %s, %s or %s)", o.UpdateMode, UPDATE_REPLACE, UPDATE_SKIP, UPDATE_FAIL)
+	}
+	if o.Compression < 0 || o.Compression > 9 {
+		return errors.Newf("invalid compression level %d", o.Compression)
+	}
 
 	if o.GenDir == "" {
 		o.GenDir = "gen"
@@ -54,7 +106,10 @@ func (o *Options) Complete(ctx clictx.Context) error {
 		o.BuildDir = o.GenDir + "/ocm"
 	}
 	if o.PluginDir == "" {
-		o.PluginDir = o.BuildDir + "/buildplugins"
+		o.PluginDir = DefaultPluginDir()
+		if o.PluginDir == "" {
+			o.PluginDir = o.BuildDir + "/buildplugins"
+		}
 	}
 	if o.Archive == "" {
 		o.Archive = o.BuildDir + "/build.ctf"
@@ -0,0 +1,277 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mandelsoft/goutils/errors"
+	clictx "ocm.software/ocm/api/cli"
+	"ocm.software/ocm/api/ocm"
+	"ocm.software/ocm/api/ocm/extensions/repositories/ctf"
+	"ocm.software/ocm/api/ocm/tools/transfer"
+	"ocm.software/ocm/api/ocm/tools/transfer/transferhandler/standard"
+	"ocm.software/ocm/api/utils/accessobj"
+	"ocm.software/ocm/api/utils/runtime"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+	"github.com/mandelsoft/ocm-build/plugincache"
+)
+
+// PruneOptions describes the selection of cache entries to be removed
+// from the plugin cache.
+type PruneOptions struct {
+	// BuildFiles keeps all entries referenced by one of the given build files.
+	BuildFiles []string
+	// OlderThan removes entries not used for the given duration.
+	OlderThan time.Duration
+	// All removes all entries.
+	All bool
+	// DryRun only reports the entries to be removed.
+	DryRun bool
+}
+
+func pluginCache(ctx clictx.Context, opts *Options) (*plugincache.PluginCache, error) {
+	err := opts.Complete(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return plugincache.New(ctx.OCMContext(), opts.PluginDir, opts.Printer, &plugincache.Options{AllowUnsigned: opts.AllowUnsigned, AllowUnverified: opts.AllowUnverified})
+}
+
+// ListPlugins lists the entries of the plugin cache.
+func ListPlugins(ctx clictx.Context, opts Options, w io.Writer) error {
+	cache, err := pluginCache(ctx, &opts)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "HASH\tCOMPONENT\tVERSION\tRESOURCE\tDIGEST\tSIZE\tLAST USED\n")
+	for _, e := range cache.Entries() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", short(e.Hash(), 12), e.Id.Component, e.Id.Version, e.Id.Resource, short(e.Digest, 12), e.Size(), lastUsed(e.LastUsedTime()))
+	}
+	return tw.Flush()
+}
+
+// InspectPlugins shows the complete cache information for the entries
+// matching the given ids (see plugincache.PluginCache.Lookup).
+func InspectPlugins(ctx clictx.Context, opts Options, w io.Writer, ids ...string) error {
+	cache, err := pluginCache(ctx, &opts)
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		Hash     string           `json:"hash"`
+		Path     string           `json:"path"`
+		Size     int64            `json:"size"`
+		LastUsed time.Time        `json:"lastUsed"`
+		Info     plugincache.Info `json:"info"`
+	}
+
+	list := errors.ErrListf("inspect")
+	for _, id := range ids {
+		entries := cache.Lookup(id)
+		if len(entries) == 0 {
+			list.Add(errors.Newf("no cached plugin found for %q", id))
+			continue
+		}
+		for _, e := range entries {
+			data, err := runtime.DefaultYAMLEncoding.Marshal(&entry{
+				Hash:     e.Hash(),
+				Path:     e.Path(),
+				Size:     e.Size(),
+				LastUsed: e.LastUsedTime(),
+				Info:     e.Info,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "---\n%s", string(data))
+		}
+	}
+	return list.Result()
+}
+
+// PrunePlugins removes the entries from the plugin cache, which are not
+// referenced by one of the given build files or which have not been used
+// for the given duration.
+func PrunePlugins(ctx clictx.Context, opts Options, popts PruneOptions) error {
+	if len(popts.BuildFiles) == 0 && popts.OlderThan == 0 && !popts.All {
+		return errors.Newf("build files, age or all required for pruning")
+	}
+	cache, err := pluginCache(ctx, &opts)
+	if err != nil {
+		return err
+	}
+
+	var specs []buildfile.Plugin
+	var locks []*plugincache.Lock
+	for _, f := range popts.BuildFiles {
+		o := opts
+		o.BuildFile = f
+		o.LockFile = plugincache.LockFile(f)
+		bd, err := LoadBuildFile(ctx.FileSystem(), &o)
+		if err != nil {
+			return errors.Wrapf(err, "build file %q", f)
+		}
+		specs = append(specs, bd.Plugins()...)
+		lock, err := plugincache.ReadLock(ctx.FileSystem(), o.LockFile)
+		if err != nil {
+			return err
+		}
+		if lock != nil {
+			locks = append(locks, lock)
+		}
+	}
+
+	printer := opts.Printer
+	if popts.DryRun {
+		printer.Printf("pruning plugin cache %s (dry run)...\n", opts.PluginDir)
+	} else {
+		printer.Printf("pruning plugin cache %s...\n", opts.PluginDir)
+	}
+	printer = printer.AddGap("  ")
+
+	now := time.Now()
+	list := errors.ErrListf("prune")
+	for _, e := range cache.Entries() {
+		reason := ""
+		if popts.All {
+			reason = "all"
+		}
+		if len(popts.BuildFiles) > 0 && !referenced(&e, specs, locks) {
+			reason = "unreferenced"
+		}
+		if popts.OlderThan > 0 && now.Sub(e.LastUsedTime()) > popts.OlderThan {
+			reason = "unused since " + lastUsed(e.LastUsedTime())
+		}
+		if reason == "" {
+			continue
+		}
+		printer.Printf("removing %s (%s): %s\n", e.Id.String(), short(e.Hash(), 12), reason)
+		if !popts.DryRun {
+			list.Add(cache.Remove(&e))
+		}
+	}
+	return list.Result()
+}
+
+func referenced(e *plugincache.Entry, specs []buildfile.Plugin, locks []*plugincache.Lock) bool {
+	for _, s := range specs {
+		if e.Matches(&s) {
+			return true
+		}
+	}
+	for _, l := range locks {
+		for _, le := range l.Plugins {
+			if le.Component == e.Id.Component && le.Version == e.Id.Version && le.Resource == e.Id.Resource {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func short(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func lastUsed(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+// ExportPlugins resolves all plugins used by the build file and transfers
+// their component versions into a transport archive (plugin bundle).
+func ExportPlugins(ctx clictx.Context, opts Options, archive string) error {
+	e, err := New(ctx, opts)
+	if err != nil {
+		return err
+	}
+	err = e.ResolvePlugins()
+	if err != nil {
+		return err
+	}
+	return e.ExportPlugins(archive)
+}
+
+func (e *Execution) ExportPlugins(archive string) error {
+	o := *e.opts
+	o.Archive = archive
+	o.Create = true
+	target, err := Archive(e.ctx, &o)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	thdlr, err := standard.New(standard.ResourcesByValue(), standard.Overwrite(true))
+	if err != nil {
+		return err
+	}
+
+	sess := ocm.NewSession(nil)
+	defer sess.Close()
+
+	printer := e.opts.Printer
+	printer.Printf("exporting build plugins to %s...\n", archive)
+	printer = printer.AddGap("  ")
+
+	done := map[plugincache.HashId]bool{}
+	for _, info := range e.plugins.Resolved() {
+		id := plugincache.HashId{Component: info.Id.Component, Version: info.Id.Version}
+		if done[id] {
+			continue
+		}
+		done[id] = true
+		if info.Repository == nil {
+			return errors.Newf("no repository known for plugin %s (reresolve required)", info.Id.String())
+		}
+		spec, err := e.ctx.OCMContext().RepositorySpecForConfig(info.Repository, nil)
+		if err != nil {
+			return errors.Wrapf(err, "invalid repository for plugin %s", info.Id.String())
+		}
+		repo, err := sess.LookupRepository(e.ctx.OCMContext(), spec)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get repository for plugin %s", info.Id.String())
+		}
+		cv, err := sess.LookupComponentVersion(repo, id.Component, id.Version)
+		if err != nil {
+			return errors.Wrapf(err, "plugin %s", info.Id.String())
+		}
+		printer.Printf("exporting %s...\n", id.String())
+		err = transfer.TransferVersion(printer.AddGap("  "), nil, cv, target, thdlr)
+		if err != nil {
+			return errors.Wrapf(err, "cannot transfer plugin %s", info.Id.String())
+		}
+	}
+	return nil
+}
+
+// ImportPlugins installs the plugins provided by a plugin bundle
+// into the plugin cache.
+func ImportPlugins(ctx clictx.Context, opts Options, archive string) error {
+	cache, err := pluginCache(ctx, &opts)
+	if err != nil {
+		return err
+	}
+	repo, err := ctf.Open(ctx.OCMContext(), accessobj.ACC_READONLY, archive, 0, ctx.FileSystem())
+	if err != nil {
+		return errors.Wrapf(err, "cannot open plugin bundle %q", archive)
+	}
+	defer repo.Close()
+
+	opts.Printer.Printf("importing build plugins from %s...\n", archive)
+	infos, err := cache.Import(repo)
+	for _, info := range infos {
+		opts.Printer.Printf("  imported %s\n", info.Id.String())
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"runtime/debug"
+	"time"
+
+	metav1 "ocm.software/ocm/api/ocm/compdesc/meta/v1"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/rscs"
+
+	"github.com/mandelsoft/ocm-build/plugincache"
+	"github.com/mandelsoft/ocm-build/state"
+	"github.com/mandelsoft/ocm-build/utils"
+)
+
+const (
+	PROVENANCE_LABEL      = "ocm.software/build/provenance"
+	PROVENANCE_BUILD_TYPE = "https://ocm.software/ocm-build/step/v1"
+)
+
+// Provenance is a SLSA (v1) style provenance predicate describing
+// the build step creating a resource.
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   ExternalParameters   `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+type ExternalParameters struct {
+	Step   string          `json:"step"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+type RunDetails struct {
+	Builder  Builder       `json:"builder"`
+	Metadata BuildMetadata `json:"metadata"`
+}
+
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+type BuildMetadata struct {
+	StartedOn  time.Time `json:"startedOn"`
+	FinishedOn time.Time `json:"finishedOn"`
+}
+
+// NewProvenance describes the execution of a build step.
+func NewProvenance(step string, p *plugincache.Plugin, config json.RawMessage, git *utils.GitInfo, start, end time.Time) *Provenance {
+	prov := &Provenance{
+		BuildDefinition: BuildDefinition{
+			BuildType: PROVENANCE_BUILD_TYPE,
+			ExternalParameters: ExternalParameters{
+				Step:   step,
+				Config: config,
+			},
+		},
+		RunDetails: RunDetails{
+			Builder: BuilderIdentity(),
+			Metadata: BuildMetadata{
+				StartedOn:  start.UTC(),
+				FinishedOn: end.UTC(),
+			},
+		},
+	}
+	if git != nil {
+		prov.BuildDefinition.ResolvedDependencies = append(prov.BuildDefinition.ResolvedDependencies, ResourceDescriptor{
+			Name:   "source",
+			URI:    "git+" + git.Repository,
+			Digest: map[string]string{"gitCommit": git.Commit},
+		})
+	}
+
+	plugin := ResourceDescriptor{
+		Name: "plugin",
+		URI:  "file://" + p.Path(),
+	}
+	if p.IsBuiltin() {
+		plugin.URI = "builtin:" + p.Path()
+	}
+	if info := p.Info(); info != nil {
+		plugin.URI = fmt.Sprintf("ocm://%s", info.Id.String())
+		// the blob digest may use another algorithm, the file digest
+		// is always SHA-256.
+		if info.FileDigest != "" {
+			plugin.Digest = map[string]string{"sha256": info.FileDigest}
+		}
+	}
+	prov.BuildDefinition.ResolvedDependencies = append(prov.BuildDefinition.ResolvedDependencies, plugin)
+	return prov
+}
+
+// BuilderIdentity describes the builder running the build.
+func BuilderIdentity() Builder {
+	host, _ := os.Hostname()
+	id := "ocm-build://" + host
+	if u, err := user.Current(); err == nil {
+		id = "ocm-build://" + u.Username + "@" + host
+	}
+	b := Builder{ID: id}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		b.Version = map[string]string{
+			"ocm-build": bi.Main.Version,
+			"go":        bi.GoVersion,
+		}
+	}
+	return b
+}
+
+// AddProvenance attaches the provenance label to the given resources.
+func AddProvenance(prov *Provenance, resources ...*rscs.ResourceSpec) error {
+	data, err := json.Marshal(prov)
+	if err != nil {
+		return err
+	}
+	for _, r := range resources {
+		r.Labels = state.MergeLabels(r.Labels, metav1.Labels{{Name: PROVENANCE_LABEL, Value: data}})
+	}
+	return nil
+}
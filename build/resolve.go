@@ -2,13 +2,14 @@ package build
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/mandelsoft/goutils/errors"
+	"github.com/mandelsoft/goutils/maputils"
 	clictx "ocm.software/ocm/api/cli"
 	"ocm.software/ocm/api/utils/misc"
 
 	"github.com/mandelsoft/ocm-build/buildfile"
+	"github.com/mandelsoft/ocm-build/plugincache"
 )
 
 func Resolve(ctx clictx.Context, opts Options) error {
@@ -19,11 +20,29 @@ func Resolve(ctx clictx.Context, opts Options) error {
 	return e.Resolve()
 }
 
+// Resolve resolves the used build plugins and writes the lock file.
 func (e *Execution) Resolve() error {
+	err := e.ResolvePlugins()
+	if err != nil || e.opts.Locked {
+		return err
+	}
+	return e.WriteLock()
+}
+
+// ResolvePlugins resolves the used build plugins without
+// writing the lock file.
+func (e *Execution) ResolvePlugins() error {
 	printer := e.opts.Printer
 	printer.Printf("resolving build plugins...\n")
 	printer = printer.AddGap("  ")
 
+	if e.opts.Offline {
+		err := e.CheckOffline()
+		if err != nil {
+			return err
+		}
+	}
+
 	if len(e.buildfile.Builds) > 0 {
 		printer.Printf("resolving build steps....\n")
 		err := e.ResolveBuilds(printer.AddGap("  "), e.buildfile.Builds, "")
@@ -35,22 +54,8 @@ func (e *Execution) Resolve() error {
 		printer.Printf("resolving component build steps....\n")
 		printer := printer.AddGap("  ")
 		for _, c := range e.buildfile.Components {
-			if len(e.opts.Components) > 0 {
-				found := false
-				for _, t := range e.opts.Components {
-					i := strings.Index(t, ":")
-					if i < 0 {
-						found = t == c.Name
-					} else {
-						found = t[:i] == c.Name && (t[i+1:] == c.Version || (c.Version == "" && t[i+1:] == e.state.BuildFile.Version))
-					}
-					if found {
-						break
-					}
-				}
-				if !found {
-					continue
-				}
+			if !e.selected(&c) {
+				continue
 			}
 			if c.Version == "" {
 				c.Version = e.buildfile.Version
@@ -62,12 +67,57 @@ func (e *Execution) Resolve() error {
 			}
 		}
 	}
+	if len(e.buildfile.PostBuilds) > 0 {
+		printer.Printf("resolving post build steps....\n")
+		err := e.ResolveBuilds(printer.AddGap("  "), e.buildfile.PostBuilds, "post build ")
+		if err != nil {
+			return err
+		}
+	}
+	if len(e.buildfile.PluginAliases) > 0 {
+		// aliases may be used by generated components
+		printer.Printf("resolving plugin aliases....\n")
+		printer := printer.AddGap("  ")
+		for _, n := range maputils.OrderedKeys(e.buildfile.PluginAliases) {
+			a := e.buildfile.PluginAliases[n]
+			p, err := e.Plugin(&a.Plugin)
+			if err == nil && !p.IsBuiltin() {
+				_, err = e.plugins.Describe(p)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "plugin alias %q", n)
+			}
+			printer.Printf("%s[%s]\n", n, p.String())
+		}
+	}
 	return nil
 }
 
+// WriteLock writes the lock file for the resolved plugins. If only
+// selected components are resolved, an existing lock file is updated.
+func (e *Execution) WriteLock() error {
+	lock := e.plugins.Options().Lock
+	if lock == nil || len(e.opts.Components) == 0 {
+		lock = plugincache.NewLock()
+	}
+	for _, info := range e.plugins.Resolved() {
+		lock.Add(&info)
+	}
+	e.opts.Printer.Printf("writing lock file %s...\n", e.opts.LockFile)
+	return lock.Write(e.fs, e.opts.LockFile)
+}
+
 func (e *Execution) ResolveBuilds(printer misc.Printer, builds []buildfile.Build, ectx string) error {
 	for i, b := range builds {
-		p, err := e.plugins.Get(&b.Plugin, e.dir)
+		if b.Local != nil {
+			printer.Printf("step %d[local %s[%s]]\n", i+1, b.Local.Component, b.Local.Resource)
+			continue
+		}
+		p, err := e.Plugin(&b.Plugin)
+		if err == nil && !p.IsBuiltin() {
+			// cache plugin descriptor
+			_, err = e.plugins.Describe(p)
+		}
 		if err != nil {
 			return errors.Wrapf(err, "%sstep %d", ectx, i+1)
 		}
@@ -0,0 +1,178 @@
+package build
+
+import (
+	"encoding/json"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/mandelsoft/goutils/errors"
+	"ocm.software/ocm/api/utils/misc"
+
+	"github.com/mandelsoft/ocm-build/plugincache"
+	"github.com/mandelsoft/ocm-build/ppi"
+	"github.com/mandelsoft/ocm-build/state"
+)
+
+// pluginServer is a plugin process started in server mode.
+// It is used for all build steps using the same plugin.
+type pluginServer struct {
+	cmd     *exec.Cmd
+	in      io.WriteCloser
+	channel *ppi.RPCChannel
+	id      int64
+	err     error
+}
+
+func serverKey(p *plugincache.Plugin) string {
+	return strings.Join(append([]string{p.Path()}, p.Args()...), " ")
+}
+
+// server provides the running server for a plugin. It is started
+// on first use.
+func (e *Execution) server(p *plugincache.Plugin) (*pluginServer, error) {
+	key := serverKey(p)
+	if s := e.servers[key]; s != nil {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return s, nil
+	}
+
+	cmd := exec.Command(p.Path(), p.Args(ppi.OPT_SERVE)...)
+	cmd.Stderr = e.ctx.StdOut()
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot start plugin server %s", p.String())
+	}
+	s := &pluginServer{
+		cmd:     cmd,
+		in:      in,
+		channel: ppi.NewRPCChannel(out, in),
+	}
+	if e.servers == nil {
+		e.servers = map[string]*pluginServer{}
+	}
+	e.servers[key] = s
+	return s, nil
+}
+
+// call sends a request to the server and waits for the response.
+// Event notifications received meanwhile are passed to the handler.
+func (s *pluginServer) call(method string, params interface{}, result interface{}, handler func(msg *ppi.RPCMessage)) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	s.id++
+	id := s.id
+	err = s.channel.Write(&ppi.RPCMessage{ID: &id, Method: method, Params: data})
+	if err != nil {
+		return s.failed(err)
+	}
+	for {
+		msg, err := s.channel.Read()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.Newf("plugin server terminated")
+			}
+			return s.failed(err)
+		}
+		if msg.ID == nil {
+			if msg.Error != nil {
+				return s.failed(msg.Error)
+			}
+			if handler != nil {
+				handler(msg)
+			}
+			continue
+		}
+		if *msg.ID != id {
+			return s.failed(errors.Newf("unexpected response id %d (expected %d)", *msg.ID, id))
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			err = json.Unmarshal(msg.Result, result)
+			if err != nil {
+				return errors.Wrapf(err, "cannot unmarshal plugin response")
+			}
+		}
+		return nil
+	}
+}
+
+// failed marks the server as unusable and terminates the process.
+func (s *pluginServer) failed(err error) error {
+	s.err = errors.Wrapf(err, "plugin server failed")
+	s.in.Close()
+	s.cmd.Process.Kill()
+	s.cmd.Wait()
+	return s.err
+}
+
+func (s *pluginServer) shutdown() error {
+	if s.err != nil {
+		return nil
+	}
+	err := s.call(ppi.METHOD_SHUTDOWN, nil, nil, nil)
+	s.in.Close()
+	if werr := s.cmd.Wait(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// executePluginServer executes a build step using a plugin server.
+func (e *Execution) executePluginServer(printer misc.Printer, step string, p *plugincache.Plugin, index int, config json.RawMessage, env *state.Environment) (*state.Descriptor, error) {
+	s, err := e.server(p)
+	if err != nil {
+		return nil, err
+	}
+	req := &ppi.Request{
+		Protocol:    ppi.PROTOCOL_V2,
+		Environment: *env,
+		Index:       index,
+		Config:      config,
+		State:       e.state,
+	}
+	var result ppi.Response
+	err = s.call(ppi.METHOD_PROCESS, req, &result, func(msg *ppi.RPCMessage) {
+		if msg.Method != ppi.METHOD_EVENT {
+			return
+		}
+		var ev ppi.Event
+		if json.Unmarshal(msg.Params, &ev) == nil {
+			e.recordEvent(printer, step, &ev)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	if result.State == nil {
+		return nil, errors.Newf("no state in plugin response")
+	}
+	return result.State, nil
+}
+
+// StopServers terminates all running plugin servers.
+func (e *Execution) StopServers() error {
+	list := errors.ErrListf("stopping plugin servers")
+	for k, s := range e.servers {
+		list.Add(errors.Wrapf(s.shutdown(), "%s", k))
+	}
+	e.servers = nil
+	return list.Result()
+}
@@ -0,0 +1,65 @@
+package build
+
+import (
+	"fmt"
+
+	"github.com/mandelsoft/goutils/errors"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+	"github.com/mandelsoft/ocm-build/plugincache"
+)
+
+// Validate checks the configs of all selected build steps against the
+// descriptors of the used plugins before any step is executed.
+// Local plugins are validated when they are used.
+func (e *Execution) Validate() error {
+	list := errors.ErrListf("invalid build steps")
+	e.validateBuilds(list, e.buildfile.Builds, -1, "")
+	for _, c := range e.buildfile.Components {
+		if e.selected(&c) {
+			e.validateBuilds(list, c.Builds, 0, fmt.Sprintf("component %s, ", c.Name))
+		}
+	}
+	e.validateBuilds(list, e.buildfile.PostBuilds, -1, "post build ")
+	return list.Result()
+}
+
+// ValidateBuilds validates a list of build steps. A negative index
+// describes generic build steps.
+func (e *Execution) ValidateBuilds(builds []buildfile.Build, index int, ectx string) error {
+	list := errors.ErrListf("invalid build steps")
+	e.validateBuilds(list, builds, index, ectx)
+	return list.Result()
+}
+
+func (e *Execution) validateBuilds(list *errors.ErrorList, builds []buildfile.Build, index int, ectx string) {
+	for i, b := range builds {
+		if b.Local != nil {
+			continue
+		}
+		p, err := e.Plugin(&b.Plugin)
+		if err == nil {
+			err = e.ValidateStep(p, &b, index)
+		}
+		if err != nil {
+			list.Add(errors.Wrapf(err, "%sstep %d", ectx, i+1))
+		}
+	}
+}
+
+// ValidateStep validates a build step against the plugin descriptor.
+// A negative index describes a generic build step.
+func (e *Execution) ValidateStep(p *plugincache.Plugin, b *buildfile.Build, index int) error {
+	d, err := e.plugins.Describe(p)
+	if err != nil || d == nil {
+		return err
+	}
+	if !d.Supports(index) {
+		kind := "component"
+		if index < 0 {
+			kind = "generic"
+		}
+		return fmt.Errorf("plugin %s cannot be used for %s build steps", d.Name, kind)
+	}
+	return d.ConfigSchema.Validate(b.Config)
+}
@@ -0,0 +1,241 @@
+package build
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/mandelsoft/goutils/errors"
+	"github.com/mandelsoft/vfs/pkg/vfs"
+	clictx "ocm.software/ocm/api/cli"
+	"ocm.software/ocm/api/ocm"
+	"ocm.software/ocm/api/ocm/compdesc"
+	"ocm.software/ocm/api/ocm/extensions/repositories/ctf"
+	"ocm.software/ocm/api/utils/accessobj"
+)
+
+// VerifyReproducible executes the build twice in separate generation
+// directories and compares the resulting component versions.
+func VerifyReproducible(ctx clictx.Context, opts Options) error {
+	err := opts.Complete(ctx)
+	if err != nil {
+		return err
+	}
+
+	fs := ctx.FileSystem()
+	printer := opts.Printer
+	printer.Printf("verifying reproducibility of build...\n")
+
+	var runs [2]*Execution
+	for i := range runs {
+		o := opts
+		o.BuildDir = vfs.Join(fs, opts.BuildDir, "verify", fmt.Sprintf("run%d", i+1))
+		o.Archive = vfs.Join(fs, o.BuildDir, "build.ctf")
+		o.Format = ctf.FormatDirectory
+		o.Mode = 0
+		o.Create = true
+		o.Force = true
+		o.UpdateMode = UPDATE_REPLACE
+		o.Printer = printer.AddGap("  ")
+
+		printer.Printf("build run %d in %s...\n", i+1, o.BuildDir)
+		if ok, err := vfs.DirExists(fs, o.BuildDir); ok && err == nil {
+			err = fs.RemoveAll(o.BuildDir)
+			if err != nil {
+				return errors.Wrapf(err, "cannot cleanup %s", o.BuildDir)
+			}
+		}
+		e, err := New(ctx, o)
+		if err != nil {
+			return err
+		}
+		err = e.Run()
+		if err != nil {
+			return errors.Wrapf(err, "build run %d", i+1)
+		}
+		runs[i] = e
+	}
+	return CompareBuilds(runs[0], runs[1])
+}
+
+// CompareBuilds compares the normalized component descriptors and the
+// resource content of the component versions created by two executions.
+// Every difference names the build step (and plugin) producing the
+// resource.
+func CompareBuilds(a, b *Execution) error {
+	printer := a.opts.Printer
+	printer.Printf("comparing builds...\n")
+
+	repoA, err := ctf.Open(a.ctx.OCMContext(), accessobj.ACC_READONLY, a.opts.Archive, 0, a.opts.Format, a.fs)
+	if err != nil {
+		return err
+	}
+	defer repoA.Close()
+	repoB, err := ctf.Open(b.ctx.OCMContext(), accessobj.ACC_READONLY, b.opts.Archive, 0, b.opts.Format, b.fs)
+	if err != nil {
+		return err
+	}
+	defer repoB.Close()
+
+	list := errors.ErrListf("build not reproducible")
+	for _, c := range builtComponents(a, b) {
+		printer.AddGap("  ").Printf("component %s:%s...\n", c.name, c.version)
+		switch {
+		case !c.inA:
+			list.Add(fmt.Errorf("component %s:%s missing in run 1", c.name, c.version))
+			continue
+		case !c.inB:
+			list.Add(fmt.Errorf("component %s:%s missing in run 2", c.name, c.version))
+			continue
+		}
+		err := compareComponentVersion(a, b, repoA, repoB, c.name, c.version, list)
+		if err != nil {
+			return err
+		}
+	}
+	if list.Len() == 0 {
+		printer.Printf("build is reproducible\n")
+	}
+	return list.Result()
+}
+
+type builtComponent struct {
+	name, version string
+	inA, inB      bool
+}
+
+// builtComponents provides the union of the component versions
+// built by two executions.
+func builtComponents(a, b *Execution) []*builtComponent {
+	var result []*builtComponent
+	index := map[string]*builtComponent{}
+	add := func(e *Execution, run int) {
+		for _, c := range e.state.Components {
+			key := c.Name + ":" + c.Version
+			bc := index[key]
+			if bc == nil {
+				bc = &builtComponent{name: c.Name, version: c.Version}
+				index[key] = bc
+				result = append(result, bc)
+			}
+			if run == 1 {
+				bc.inA = true
+			} else {
+				bc.inB = true
+			}
+		}
+	}
+	add(a, 1)
+	add(b, 2)
+	return result
+}
+
+// producer describes the build steps producing a resource in
+// both runs.
+func producer(a, b *Execution, name, vers string, meta *compdesc.ElementMeta) string {
+	pa := a.Producer(name, vers, meta.Name, meta.ExtraIdentity)
+	pb := b.Producer(name, vers, meta.Name, meta.ExtraIdentity)
+	switch {
+	case pa == "" && pb == "":
+		return "unknown step"
+	case pa == pb || pb == "":
+		return pa
+	case pa == "":
+		return pb
+	default:
+		return fmt.Sprintf("%s in run 1, %s in run 2", pa, pb)
+	}
+}
+
+func compareComponentVersion(a, b *Execution, repoA, repoB ocm.Repository, name, vers string, list *errors.ErrorList) error {
+	printer := a.opts.Printer.AddGap("    ")
+
+	cvA, err := repoA.LookupComponentVersion(name, vers)
+	if err != nil {
+		return errors.Wrapf(err, "run 1")
+	}
+	defer cvA.Close()
+	cvB, err := repoB.LookupComponentVersion(name, vers)
+	if err != nil {
+		return errors.Wrapf(err, "run 2")
+	}
+	defer cvB.Close()
+
+	type resource struct {
+		meta   *compdesc.ElementMeta
+		digest string
+	}
+	resourcesB := map[string]*resource{}
+	var keysB []string
+	for _, r := range cvB.GetResources() {
+		d, err := contentDigest(r)
+		if err != nil {
+			return err
+		}
+		key := ResourceKey(name, vers, r.Meta().Name, r.Meta().ExtraIdentity)
+		resourcesB[key] = &resource{meta: &r.Meta().ElementMeta, digest: d}
+		keysB = append(keysB, key)
+	}
+
+	for _, r := range cvA.GetResources() {
+		key := ResourceKey(name, vers, r.Meta().Name, r.Meta().ExtraIdentity)
+		d, err := contentDigest(r)
+		if err != nil {
+			return err
+		}
+		o := resourcesB[key]
+		delete(resourcesB, key)
+		switch {
+		case o == nil:
+			list.Add(fmt.Errorf("resource %s missing in run 2 (produced by %s)", key, producer(a, b, name, vers, &r.Meta().ElementMeta)))
+		case o.digest != d:
+			list.Add(fmt.Errorf("resource %s differs (produced by %s)", key, producer(a, b, name, vers, &r.Meta().ElementMeta)))
+		default:
+			printer.Printf("resource %s: %s\n", key, d)
+			continue
+		}
+		printer.Printf("resource %s: DIFFERENT\n", key)
+	}
+	for _, key := range keysB {
+		if r := resourcesB[key]; r != nil {
+			list.Add(fmt.Errorf("resource %s missing in run 1 (produced by %s)", key, producer(a, b, name, vers, r.meta)))
+			printer.Printf("resource %s: DIFFERENT\n", key)
+		}
+	}
+
+	ndA, err := compdesc.Normalize(cvA.GetDescriptor(), compdesc.JsonNormalisationV3)
+	if err != nil {
+		return errors.Wrapf(err, "cannot normalize component descriptor of %s:%s", name, vers)
+	}
+	ndB, err := compdesc.Normalize(cvB.GetDescriptor(), compdesc.JsonNormalisationV3)
+	if err != nil {
+		return errors.Wrapf(err, "cannot normalize component descriptor of %s:%s", name, vers)
+	}
+	if !bytes.Equal(ndA, ndB) {
+		list.Add(fmt.Errorf("normalized component descriptor of %s:%s differs", name, vers))
+	}
+	return nil
+}
+
+func contentDigest(r ocm.ResourceAccess) (string, error) {
+	m, err := r.AccessMethod()
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot access resource %s", r.Meta().Name)
+	}
+	defer m.Close()
+
+	reader, err := m.Reader()
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot read resource %s", r.Meta().Name)
+	}
+	defer reader.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, reader)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot read resource %s", r.Meta().Name)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
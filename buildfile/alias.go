@@ -0,0 +1,102 @@
+package buildfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/mandelsoft/goutils/errors"
+)
+
+// PluginAlias gives a name to a plugin specification. The config
+// is used as default config for all build steps using the alias.
+type PluginAlias struct {
+	Plugin `json:",inline"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// ResolveAliases replaces the plugin aliases used by build steps by the
+// plugin specification and merges the default config of the alias
+// into the step config.
+func (d *Descriptor) ResolveAliases() error {
+	list := errors.ErrListf("invalid plugin aliases")
+	resolve := func(builds []Build, ectx string) {
+		for i := range builds {
+			err := d.ResolveAlias(&builds[i])
+			if err != nil {
+				list.Add(errors.Wrapf(err, "%sstep %d", ectx, i+1))
+			}
+		}
+	}
+	resolve(d.Builds, "")
+	for _, c := range d.Components {
+		resolve(c.Builds, fmt.Sprintf("component %s, ", c.Name))
+	}
+	resolve(d.PostBuilds, "post build ")
+	return list.Result()
+}
+
+// ResolveAlias replaces the plugin alias used by a build step.
+func (d *Descriptor) ResolveAlias(b *Build) error {
+	if b.Alias == "" {
+		return nil
+	}
+	a, ok := d.PluginAliases[b.Alias]
+	if !ok {
+		return fmt.Errorf("unknown plugin alias %q", b.Alias)
+	}
+	if !reflect.DeepEqual(b.Plugin, Plugin{}) {
+		return fmt.Errorf("for plugin alias %q no other plugin specification is possible", b.Alias)
+	}
+	cfg, err := MergeConfig(a.Config, b.Config)
+	if err != nil {
+		return errors.Wrapf(err, "plugin alias %q", b.Alias)
+	}
+	b.Plugin = a.Plugin
+	b.Config = cfg
+	return nil
+}
+
+// MergeConfig deep-merges a config into a default config. Nested maps
+// are merged, all other values of the config replace the default values.
+func MergeConfig(def, cfg json.RawMessage) (json.RawMessage, error) {
+	if isNull(def) {
+		return cfg, nil
+	}
+	if isNull(cfg) {
+		return def, nil
+	}
+	var d, c interface{}
+	err := json.Unmarshal(def, &d)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid default config")
+	}
+	err = json.Unmarshal(cfg, &c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config")
+	}
+	return json.Marshal(mergeValue(d, c))
+}
+
+func mergeValue(def, val interface{}) interface{} {
+	dm, dok := def.(map[string]interface{})
+	vm, vok := val.(map[string]interface{})
+	if !dok || !vok {
+		return val
+	}
+	result := map[string]interface{}{}
+	for k, v := range dm {
+		result[k] = v
+	}
+	for k, v := range vm {
+		if d, ok := result[k]; ok {
+			v = mergeValue(d, v)
+		}
+		result[k] = v
+	}
+	return result
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
@@ -0,0 +1,158 @@
+package buildfile
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func equalJSON(t *testing.T, a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("invalid JSON %q: %s", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("invalid JSON %q: %s", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestMergeConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		def      string
+		cfg      string
+		expected string
+		err      string
+	}{
+		{"no default", ``, `{"a":1}`, `{"a":1}`, ""},
+		{"null default", `null`, `{"a":1}`, `{"a":1}`, ""},
+		{"no config", `{"a":1}`, ``, `{"a":1}`, ""},
+		{"null config", `{"a":1}`, `null`, `{"a":1}`, ""},
+		{"nothing", ``, ``, ``, ""},
+		{"additional fields", `{"a":1}`, `{"b":2}`, `{"a":1,"b":2}`, ""},
+		{"config wins", `{"a":1,"b":2}`, `{"a":3}`, `{"a":3,"b":2}`, ""},
+		{"nested maps", `{"a":{"x":1,"y":{"z":2}},"b":1}`, `{"a":{"y":{"w":3}}}`, `{"a":{"x":1,"y":{"z":2,"w":3}},"b":1}`, ""},
+		{"lists are replaced", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`, ""},
+		{"map replaced by value", `{"a":{"x":1}}`, `{"a":"x"}`, `{"a":"x"}`, ""},
+		{"value replaced by map", `{"a":"x"}`, `{"a":{"x":1}}`, `{"a":{"x":1}}`, ""},
+		{"null field", `{"a":{"x":1}}`, `{"a":null}`, `{"a":null}`, ""},
+		{"non-object config", `{"a":1}`, `[1]`, `[1]`, ""},
+		{"invalid default", `{`, `{"a":1}`, ``, "invalid default config"},
+		{"invalid config", `{"a":1}`, `{`, ``, "invalid config"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := MergeConfig(json.RawMessage(tc.def), json.RawMessage(tc.cfg))
+			if tc.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, found %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalJSON(t, r, json.RawMessage(tc.expected)) {
+				t.Errorf("expected %s, found %s", tc.expected, string(r))
+			}
+		})
+	}
+}
+
+func aliases() *Descriptor {
+	return &Descriptor{
+		PluginAliases: map[string]PluginAlias{
+			"go": {
+				Plugin: Plugin{PluginRef: "ghcr.io/acme//acme.org/goexecutable"},
+				Config: json.RawMessage(`{"platforms":["linux/amd64"],"resource":{"type":"executable"}}`),
+			},
+			"execute": {
+				Plugin: Plugin{Builtin: "execute"},
+			},
+			"invalid": {
+				Plugin: Plugin{Builtin: "execute"},
+				Config: json.RawMessage(`{`),
+			},
+		},
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	tests := []struct {
+		name   string
+		build  Build
+		plugin Plugin
+		config string
+		err    string
+	}{
+		{"no alias", Build{Plugin: Plugin{Builtin: "constructor"}, Config: json.RawMessage(`{"a":1}`)}, Plugin{Builtin: "constructor"}, `{"a":1}`, ""},
+		{"alias with default config", Build{Alias: "go", Config: json.RawMessage(`{"path":"cmd","resource":{"name":"cli"}}`)}, Plugin{PluginRef: "ghcr.io/acme//acme.org/goexecutable"}, `{"path":"cmd","platforms":["linux/amd64"],"resource":{"name":"cli","type":"executable"}}`, ""},
+		{"alias overriding default config", Build{Alias: "go", Config: json.RawMessage(`{"platforms":[]}`)}, Plugin{PluginRef: "ghcr.io/acme//acme.org/goexecutable"}, `{"platforms":[],"resource":{"type":"executable"}}`, ""},
+		{"alias without default config", Build{Alias: "execute", Config: json.RawMessage(`{"cmd":"make"}`)}, Plugin{Builtin: "execute"}, `{"cmd":"make"}`, ""},
+		{"alias without config", Build{Alias: "go"}, Plugin{PluginRef: "ghcr.io/acme//acme.org/goexecutable"}, `{"platforms":["linux/amd64"],"resource":{"type":"executable"}}`, ""},
+		{"unknown alias", Build{Alias: "other"}, Plugin{}, ``, `unknown plugin alias "other"`},
+		{"alias with plugin specification", Build{Alias: "go", Plugin: Plugin{Builtin: "execute"}}, Plugin{}, ``, `for plugin alias "go" no other plugin specification is possible`},
+		{"invalid default config", Build{Alias: "invalid", Config: json.RawMessage(`{}`)}, Plugin{}, ``, `plugin alias "invalid": invalid default config`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.build
+			err := aliases().ResolveAlias(&b)
+			if tc.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, found %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(b.Plugin, tc.plugin) {
+				t.Errorf("expected plugin %+v, found %+v", tc.plugin, b.Plugin)
+			}
+			if !equalJSON(t, b.Config, json.RawMessage(tc.config)) {
+				t.Errorf("expected config %s, found %s", tc.config, string(b.Config))
+			}
+		})
+	}
+}
+
+func TestResolveAliases(t *testing.T) {
+	d := aliases()
+	d.Builds = []Build{{Alias: "execute"}}
+	d.Components = []Component{
+		{Name: "acme.org/a", Builds: []Build{{Alias: "go"}, {Alias: "unknown"}}},
+	}
+	d.PostBuilds = []Build{{Alias: "go", Plugin: Plugin{Builtin: "execute"}}}
+
+	err := d.ResolveAliases()
+	if err == nil {
+		t.Fatalf("errors not detected")
+	}
+	for _, e := range []string{
+		`component acme.org/a, step 2: unknown plugin alias "unknown"`,
+		`post build step 1: for plugin alias "go" no other plugin specification is possible`,
+	} {
+		if !strings.Contains(err.Error(), e) {
+			t.Errorf("error %q not reported: %s", e, err)
+		}
+	}
+
+	if d.Builds[0].Builtin != "execute" {
+		t.Errorf("build step not resolved")
+	}
+	if d.Components[0].Builds[0].PluginRef == "" {
+		t.Errorf("component build step not resolved")
+	}
+
+	d = aliases()
+	d.Components = []Component{{Name: "acme.org/a", Builds: []Build{{Alias: "go"}}}}
+	if err := d.ResolveAliases(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -3,6 +3,7 @@ package buildfile
 import (
 	"encoding/json"
 
+	"github.com/mandelsoft/goutils/maputils"
 	metav1 "ocm.software/ocm/api/ocm/compdesc/meta/v1"
 )
 
@@ -10,25 +11,73 @@ type Descriptor struct {
 	SchemaVersion string                 `json:"schemaVersion"`
 	Metadata      map[string]interface{} `json:"metadata"`
 
-	Version    string        `json:"version,omitempty"`
-	Provider   *Provider     `json:"provider"`
-	Labels     metav1.Labels `json:"labels,omitempty"`
-	Builds     []Build       `json:"builds,omitempty"`
-	Components []Component   `json:"components"`
+	Version       string                 `json:"version,omitempty"`
+	Provider      *Provider              `json:"provider"`
+	Labels        metav1.Labels          `json:"labels,omitempty"`
+	BuildInfo     *BuildInfo             `json:"buildInfo,omitempty"`
+	Trust         *Trust                 `json:"trust,omitempty"`
+	Mirrors       []Mirror               `json:"mirrors,omitempty"`
+	PluginAliases map[string]PluginAlias `json:"plugins,omitempty"`
+	Builds        []Build                `json:"builds,omitempty"`
+	Components    []Component            `json:"components"`
+	PostBuilds    []Build                `json:"postBuilds,omitempty"`
 }
 
 type Provider = metav1.Provider
 
+// Trust describes the signatures accepted for build plugins.
+type Trust struct {
+	// Signatures restricts the accepted signature names.
+	Signatures []string `json:"signatures,omitempty"`
+	// PublicKeys maps signature names to files containing the public key
+	// or certificate used to verify the signature.
+	PublicKeys map[string]string `json:"publicKeys,omitempty"`
+}
+
 type Component struct {
 	Name    string `json:"name"`
 	Version string `json:"version,omitempty"`
 
-	Provider *Provider     `json:"provider,omitempty"`
-	Labels   metav1.Labels `json:"labels,omitempty"`
+	Provider  *Provider     `json:"provider,omitempty"`
+	Labels    metav1.Labels `json:"labels,omitempty"`
+	BuildInfo *BuildInfo    `json:"buildInfo,omitempty"`
 
 	Builds []Build `json:"builds"`
 }
 
+// BuildInfo controls the automatically added build metadata.
+type BuildInfo struct {
+	// Source adds a git source entry for the working tree.
+	Source *bool `json:"source,omitempty"`
+	// Labels adds labels for commit, build time, builder host and dirty flag.
+	Labels *bool `json:"labels,omitempty"`
+}
+
+// Merge overwrites the settings of b with the settings of o.
+func (b *BuildInfo) Merge(o *BuildInfo) *BuildInfo {
+	var r BuildInfo
+	if b != nil {
+		r = *b
+	}
+	if o != nil {
+		if o.Source != nil {
+			r.Source = o.Source
+		}
+		if o.Labels != nil {
+			r.Labels = o.Labels
+		}
+	}
+	return &r
+}
+
+func (b *BuildInfo) AddSource() bool {
+	return b != nil && b.Source != nil && *b.Source
+}
+
+func (b *BuildInfo) AddLabels() bool {
+	return b != nil && b.Labels != nil && *b.Labels
+}
+
 func (c *Component) GetName() string {
 	return c.Name
 }
@@ -39,6 +88,8 @@ func (c *Component) GetVersion() string {
 
 type Build struct {
 	Plugin `json:",inline"`
+	// Alias refers to a plugin alias instead of a plugin specification.
+	Alias  string          `json:"plugin,omitempty"`
 	Config json.RawMessage `json:"config"`
 }
 
@@ -49,4 +100,57 @@ type Plugin struct {
 	Version    string           `json:"version,omitempty"`
 	Resource   string           `json:"resource,omitempty"`
 	Executable *json.RawMessage `json:"executable,omitempty"`
+	// Describe requests the descriptor of an executable with the
+	// option --describe. Arbitrary executables are not described
+	// by default.
+	Describe bool          `json:"describe,omitempty"`
+	Local    *LocalPlugin  `json:"local,omitempty"`
+	Source   *SourcePlugin `json:"source,omitempty"`
+	Builtin  string        `json:"builtin,omitempty"`
+	Mirrors  []Mirror      `json:"mirrors,omitempty"`
+}
+
+// SourcePlugin describes a build plugin built from Go sources.
+type SourcePlugin struct {
+	// GoPackagePath is the path of the Go package of the plugin.
+	GoPackagePath string `json:"gopkgpath"`
+	// Module is the root directory of the Go module. By default, it is
+	// determined by searching a go.mod file.
+	Module string `json:"module,omitempty"`
+}
+
+// LocalPlugin refers to a build plugin resource added to a component
+// by a previous build step.
+type LocalPlugin struct {
+	Component string `json:"component"`
+	Resource  string `json:"resource"`
+}
+
+// Mirror describes an alternative repository for plugin references.
+type Mirror struct {
+	// Repository is a repository reference or specification.
+	Repository json.RawMessage `json:"repository"`
+	// Prefer tries the mirror before the primary repository.
+	Prefer bool `json:"prefer,omitempty"`
+}
+
+// Plugins provides the plugin specifications of all build steps
+// and plugin aliases.
+func (d *Descriptor) Plugins() []Plugin {
+	var result []Plugin
+	for _, n := range maputils.OrderedKeys(d.PluginAliases) {
+		result = append(result, d.PluginAliases[n].Plugin)
+	}
+	for _, b := range d.Builds {
+		result = append(result, b.Plugin)
+	}
+	for _, c := range d.Components {
+		for _, b := range c.Builds {
+			result = append(result, b.Plugin)
+		}
+	}
+	for _, b := range d.PostBuilds {
+		result = append(result, b.Plugin)
+	}
+	return result
 }
@@ -0,0 +1,20 @@
+// Package builtins registers the bundled build plugins as builtin
+// plugins. It is used with a blank import.
+package builtins
+
+import (
+	"github.com/mandelsoft/ocm-build/build"
+	"github.com/mandelsoft/ocm-build/handlers/constructor"
+	"github.com/mandelsoft/ocm-build/handlers/dockerbuild"
+	"github.com/mandelsoft/ocm-build/handlers/execute"
+	"github.com/mandelsoft/ocm-build/handlers/goexecutable"
+	"github.com/mandelsoft/ocm-build/handlers/sbom"
+)
+
+func init() {
+	build.RegisterBuiltin(constructor.NAME, constructor.New())
+	build.RegisterBuiltin(dockerbuild.NAME, dockerbuild.New())
+	build.RegisterBuiltin(execute.NAME, execute.New())
+	build.RegisterBuiltin(goexecutable.NAME, goexecutable.New())
+	build.RegisterBuiltin(sbom.NAME, sbom.New())
+}
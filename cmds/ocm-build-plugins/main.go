@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	clictx "ocm.software/ocm/api/cli"
+	utils "ocm.software/ocm/api/ocm/ocmutils"
+	"ocm.software/ocm/api/ocm/plugin/registration"
+	"ocm.software/ocm/api/utils/template"
+
+	"github.com/mandelsoft/ocm-build/build"
+	// register bundled plugins as builtin plugins.
+	_ "github.com/mandelsoft/ocm-build/builtins"
+)
+
+// The plugin cache is managed by a separate command, because the build
+// command accepts arbitrary component names as arguments.
+func main() {
+	var opts build.Options
+
+	opts.Templater = template.Options{
+		Default: "spiff",
+		UseEnv:  false,
+	}
+	cmd := PluginsCommand(&opts)
+	cmd.Use = os.Args[0]
+	cmd.Version = "0.1.0"
+
+	cmd.SetArgs(os.Args[1:])
+	pfs := cmd.PersistentFlags()
+	pfs.StringVarP(&opts.PluginDir, "plugins", "p", "", "plugin di")
+	pfs.StringVarP(&opts.BuildFile, "buildfile", "b", "BuildFile.yaml", "build file")
+
+	err := cmd.Execute()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err.Error())
+		os.Exit(1)
+	}
+}
+
+// NewContext provides the configured OCM CLI context.
+func NewContext() clictx.Context {
+	ctx := clictx.New()
+
+	_, err := utils.Configure(ctx, "", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: configuration failed: %s\n", os.Args[0], err.Error())
+		os.Exit(1)
+	}
+	registration.RegisterExtensions(ctx)
+	return ctx
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mandelsoft/ocm-build/build"
+)
+
+// PluginsCommand provides the command for managing
+// the plugin cache.
+func PluginsCommand(opts *build.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plugins",
+		Short: "manage the build plugin cache",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "list cached build plugins",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return build.ListPlugins(NewContext(), *opts, cmd.OutOrStdout())
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "inspect {<hash>|<component>[:<version>]}",
+		Short: "show cache information for build plugins",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return build.InspectPlugins(NewContext(), *opts, cmd.OutOrStdout(), args...)
+		},
+	})
+
+	var prune build.PruneOptions
+	pcmd := &cobra.Command{
+		Use:   "prune",
+		Short: "remove unreferenced or unused build plugins from cache",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return build.PrunePlugins(NewContext(), *opts, prune)
+		},
+	}
+	fs := pcmd.Flags()
+	fs.StringArrayVarP(&prune.BuildFiles, "referenced-by", "", nil, "keep plugins referenced by build file")
+	fs.DurationVarP(&prune.OlderThan, "older-than", "", time.Duration(0), "remove plugins not used for given duration")
+	fs.BoolVarP(&prune.All, "all", "", false, "remove all plugins")
+	fs.BoolVarP(&prune.DryRun, "dry-run", "", false, "only show plugins to be removed")
+	cmd.AddCommand(pcmd)
+
+	ecmd := &cobra.Command{
+		Use:   "export <archive>",
+		Short: "export build plugins used by build file into a plugin bundle",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return build.ExportPlugins(NewContext(), *opts, args[0])
+		},
+	}
+	ecmd.Flags().BoolVarP(&opts.AllowUnsigned, "allow-unsigned", "", false, "accept build plugins without trusted signature")
+	ecmd.Flags().BoolVarP(&opts.AllowUnverified, "allow-unverified", "", false, "accept build plugins whose content cannot be verified")
+	ecmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "cleanup existing archive")
+	ecmd.Flags().BoolVarP(&opts.Locked, "locked", "", false, "use plugin versions pinned in lock file")
+	ecmd.Flags().BoolVarP(&opts.Offline, "offline", "", false, "resolve plugins only from plugin cache or lock file")
+	ecmd.Flags().StringArrayVarP(&opts.Bundles, "plugin-bundle", "", nil, "plugin bundle used as fallback repository")
+	cmd.AddCommand(ecmd)
+
+	icmd := &cobra.Command{
+		Use:   "import <archive>",
+		Short: "import build plugins from a plugin bundle into the plugin cache",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return build.ImportPlugins(NewContext(), *opts, args[0])
+		},
+	}
+	icmd.Flags().BoolVarP(&opts.AllowUnsigned, "allow-unsigned", "", false, "accept build plugins without trusted signature")
+	icmd.Flags().BoolVarP(&opts.AllowUnverified, "allow-unverified", "", false, "accept build plugins whose content cannot be verified")
+	cmd.AddCommand(icmd)
+	return cmd
+}
@@ -0,0 +1,143 @@
+package constructor
+
+import (
+	"strings"
+	"testing"
+
+	ppitesting "github.com/mandelsoft/ocm-build/ppi/testing"
+)
+
+const constructor = `
+name: acme.org/other
+version: 2.0.0
+provider:
+  name: acme.org
+labels:
+  - name: acme.org/label
+    value: v
+resources:
+  - name: doc
+    type: PlainText
+    input:
+      type: file
+      path: doc.txt
+sources:
+  - name: src
+    type: filesystem
+    input:
+      type: dir
+      path: src
+`
+
+func TestConstructor(t *testing.T) {
+	h, err := ppitesting.New(New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Cleanup()
+
+	err = h.WriteFile("ocm/component.yaml", []byte(constructor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := h.AddComponent("acme.org/test", "1.0.0")
+	err = h.Run(idx, &Config{Constructor: "ocm/component.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := h.Component(idx)
+	if c.Name != "acme.org/other" || c.Version != "2.0.0" {
+		t.Errorf("unexpected component %s:%s", c.Name, c.Version)
+	}
+	if c.Provider.Name != "acme.org" {
+		t.Errorf("unexpected provider %q", c.Provider.Name)
+	}
+	if len(c.Labels) != 1 || c.Labels[0].Name != "acme.org/label" {
+		t.Errorf("unexpected labels %v", c.Labels)
+	}
+	if r := h.Resource(idx, "doc"); r == nil || r.Type != "PlainText" {
+		t.Errorf("resource doc not found")
+	}
+	if len(h.Sources(idx)) != 1 || h.Sources(idx)[0].Name != "src" {
+		t.Errorf("source src not found")
+	}
+	if len(h.Executor.Commands()) != 0 {
+		t.Errorf("no commands expected")
+	}
+}
+
+func TestConstructorMerge(t *testing.T) {
+	h, err := ppitesting.New(New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Cleanup()
+
+	err = h.WriteFile("first.yaml", []byte("resources:\n  - name: doc\n    type: PlainText\n    input:\n      type: file\n      path: doc.txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.WriteFile("second.yaml", []byte("resources:\n  - name: doc\n    type: Markdown\n    input:\n      type: file\n      path: doc.md\n  - name: other\n    type: PlainText\n    input:\n      type: file\n      path: other.txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := h.AddComponent("acme.org/test", "1.0.0")
+	for _, f := range []string{"first.yaml", "second.yaml"} {
+		err = h.Run(idx, &Config{Constructor: f})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := h.Component(idx)
+	if c.Name != "acme.org/test" || c.Version != "1.0.0" {
+		t.Errorf("component identity must be kept: %s:%s", c.Name, c.Version)
+	}
+	if len(h.Resources(idx)) != 2 {
+		t.Fatalf("expected 2 resources, found %d", len(h.Resources(idx)))
+	}
+	if r := h.Resource(idx, "doc"); r == nil || r.Type != "Markdown" {
+		t.Errorf("resource doc not replaced")
+	}
+}
+
+func TestConstructorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"no constructor", `{}`, "constructor required in plugin config"},
+		{"constructor not found", `{"constructor":"other.yaml"}`, `cannot read constructor "other.yaml"`},
+		{"unknown templater", `{"constructor":"component.yaml","templater":"unknown"}`, "unknown templating engine"},
+		{"invalid constructor", `{"constructor":"invalid.yaml"}`, `cannot run marshal constructor "invalid.yaml"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := ppitesting.New(New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Cleanup()
+			err = h.WriteFile("component.yaml", []byte(constructor))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = h.WriteFile("invalid.yaml", []byte("resources: value\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx := h.AddComponent("acme.org/test", "1.0.0")
+
+			err = h.Run(idx, tc.config)
+			if err == nil {
+				t.Fatalf("expected error %q", tc.err)
+			}
+			if !strings.HasPrefix(err.Error(), tc.err) {
+				t.Errorf("expected error %q, found %q", tc.err, err)
+			}
+		})
+	}
+}
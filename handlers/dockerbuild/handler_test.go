@@ -0,0 +1,180 @@
+package dockerbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+	"ocm.software/ocm/api/ocm/extensions/artifacttypes"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/rscs"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/inputs/types/docker"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/inputs/types/dockermulti"
+
+	ppitesting "github.com/mandelsoft/ocm-build/ppi/testing"
+)
+
+type input struct {
+	Type     string   `json:"type"`
+	Path     string   `json:"path"`
+	Variants []string `json:"variants"`
+}
+
+func inputOf(t *testing.T, r *rscs.ResourceSpec) *input {
+	data, err := json.Marshal(r.Input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var i input
+	err = json.Unmarshal(data, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &i
+}
+
+func TestImageName(t *testing.T) {
+	tests := []struct {
+		platform string
+		expected string
+		err      bool
+	}{
+		{"linux/amd64", "image-linux-amd64:1.0.0", false},
+		{"darwin/arm64", "image-darwin-arm64:1.0.0", false},
+		{"linux", "", true},
+		{"linux/arm/v7", "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.platform, func(t *testing.T) {
+			name, err := ImageName("image", tc.platform, "1.0.0")
+			if tc.err != (err != nil) {
+				t.Fatalf("unexpected error result: %v", err)
+			}
+			if name != tc.expected {
+				t.Errorf("expected %q, found %q", tc.expected, name)
+			}
+		})
+	}
+}
+
+func TestDockerBuild(t *testing.T) {
+	tests := []struct {
+		name      string
+		platforms []string
+		root      string
+		options   []string
+	}{
+		{"single platform", []string{"linux/amd64"}, "", nil},
+		{"multiple platforms", []string{"linux/amd64", "linux/arm64"}, "", nil},
+		{"content root", []string{"linux/amd64"}, ".", []string{"--no-cache"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := ppitesting.New(New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Cleanup()
+			h.State.BuildFile.Version = "1.0.0"
+
+			err = h.WriteFile("docker/Dockerfile", []byte("FROM scratch\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx := h.AddComponent("acme.org/test", "1.0.0")
+			err = h.Run(idx, &Config{
+				Dockerfile:  "docker/Dockerfile",
+				ContentRoot: tc.root,
+				Options:     tc.options,
+				Platforms:   tc.platforms,
+				Resource:    Resource{Name: "image"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			root := filepath.Join(h.BaseDir(), "docker")
+			if tc.root != "" {
+				root = filepath.Join(h.BaseDir(), tc.root)
+			}
+			cmds := h.Executor.Commands()
+			if len(cmds) != len(tc.platforms) {
+				t.Fatalf("expected %d commands, found %d", len(tc.platforms), len(cmds))
+			}
+			var variants []string
+			for i, pl := range tc.platforms {
+				s := strings.Split(pl, "/")
+				image := fmt.Sprintf("image-%s-%s:1.0.0", s[0], s[1])
+				variants = append(variants, image)
+				args := append(append([]string{"docker", "buildx", "build", "--load", "-t", image, "--platform", pl, "--file", filepath.Join(h.BaseDir(), "docker/Dockerfile")}, tc.options...), root)
+				if !reflect.DeepEqual(cmds[i], args) {
+					t.Errorf("expected command %v, found %v", args, cmds[i])
+				}
+			}
+
+			r := h.Resource(idx, "image")
+			if r == nil {
+				t.Fatalf("resource not found")
+			}
+			if r.Type != artifacttypes.OCI_IMAGE {
+				t.Errorf("expected type %s, found %s", artifacttypes.OCI_IMAGE, r.Type)
+			}
+			i := inputOf(t, r)
+			if len(variants) == 1 {
+				if i.Type != docker.TYPE || i.Path != variants[0] {
+					t.Errorf("unexpected input %+v", i)
+				}
+			} else {
+				if i.Type != dockermulti.TYPE || !reflect.DeepEqual(i.Variants, variants) {
+					t.Errorf("unexpected input %+v", i)
+				}
+			}
+		})
+	}
+}
+
+func TestDockerBuildErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		fail   error
+		err    string
+	}{
+		{"no dockerfile", `{"resource":{"name":"image"}}`, nil, "dockerfile to build required"},
+		{"no resource name", `{"dockerfile":"Dockerfile"}`, nil, "resource name required"},
+		{"dockerfile not found", `{"dockerfile":"other/Dockerfile","platforms":["linux/amd64"],"resource":{"name":"image"}}`, nil, `dockerfile "`},
+		{"invalid platform", `{"dockerfile":"Dockerfile","platforms":["linux"],"resource":{"name":"image"}}`, nil, `invalid platform "linux"`},
+		{"build failure", `{"dockerfile":"Dockerfile","platforms":["linux/amd64"],"resource":{"name":"image"}}`, fmt.Errorf("exit status 1"), "docker build failed: exit status 1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := ppitesting.New(New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Cleanup()
+			if tc.fail != nil {
+				h.Executor.Handle("docker", ppitesting.Fail(tc.fail))
+			}
+			h.State.BuildFile.Version = "1.0.0"
+			err = h.WriteFile("Dockerfile", []byte("FROM scratch\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx := h.AddComponent("acme.org/test", "1.0.0")
+
+			err = h.Run(idx, tc.config)
+			if err == nil {
+				t.Fatalf("expected error %q", tc.err)
+			}
+			if !strings.HasPrefix(err.Error(), tc.err) {
+				t.Errorf("expected error %q, found %q", tc.err, err)
+			}
+			if len(h.Resources(idx)) != 0 {
+				t.Errorf("no resources expected")
+			}
+		})
+	}
+}
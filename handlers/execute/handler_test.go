@@ -0,0 +1,98 @@
+package execute
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+
+	ppitesting "github.com/mandelsoft/ocm-build/ppi/testing"
+)
+
+func TestExecute(t *testing.T) {
+	h, err := ppitesting.New(New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Cleanup()
+
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+	}{
+		{"simple", `{"cmd":"make"}`, []string{"make"}},
+		{"arguments", `{"cmd":["make","all"]}`, []string{"make", "all"}},
+		{"path", `{"cmd":["cat",{"path":"docs/README.md"}]}`, []string{"cat", filepath.Join(h.BaseDir(), "docs/README.md")}},
+		{"gopkgpath", `{"cmd":["go","run",{"gopkgpath":"cmd/gen"}]}`, []string{"go", "run", filepath.Join(h.BaseDir(), "cmd/gen")}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := len(h.Executor.Commands())
+			err := h.Run(-1, tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmds := h.Executor.Commands()
+			if len(cmds) != n+1 {
+				t.Fatalf("expected one command, found %d", len(cmds)-n)
+			}
+			if !reflect.DeepEqual(cmds[n], tc.args) {
+				t.Errorf("expected command %v, found %v", tc.args, cmds[n])
+			}
+		})
+	}
+}
+
+func TestExecuteComponentStep(t *testing.T) {
+	h, err := ppitesting.New(New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Cleanup()
+
+	idx := h.AddComponent("acme.org/test", "1.0.0")
+	err = h.Run(idx, `{"cmd":["make"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Resources(idx)) != 0 {
+		t.Errorf("no resources expected")
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		fail   error
+		err    string
+	}{
+		{"no command", `{}`, nil, "at least a command name is required"},
+		{"invalid argument", `{"cmd":[{"path":"a","gopkgpath":"b"}]}`, nil, "argument 0: either path or gopkgpath must be set"},
+		{"invalid config", `{"cmd":`, nil, "cannot parse config"},
+		{"failing command", `{"cmd":["make"]}`, fmt.Errorf("exit status 2"), "execution failed: exit status 2"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := ppitesting.New(New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Cleanup()
+			if tc.fail != nil {
+				h.Executor.Handle("make", ppitesting.Fail(tc.fail))
+			}
+
+			err = h.Run(-1, tc.config)
+			if err == nil {
+				t.Fatalf("expected error %q", tc.err)
+			}
+			if !strings.HasPrefix(err.Error(), tc.err) {
+				t.Errorf("expected error %q, found %q", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package goexecutable
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+	resourcetypes "ocm.software/ocm/api/ocm/extensions/artifacttypes"
+	"ocm.software/ocm/api/ocm/extraid"
+
+	ppitesting "github.com/mandelsoft/ocm-build/ppi/testing"
+)
+
+func TestGoExecutable(t *testing.T) {
+	tests := []struct {
+		name      string
+		platforms []string
+		options   []string
+	}{
+		{"host platform", nil, nil},
+		{"options", nil, []string{"-trimpath"}},
+		{"platforms", []string{"linux/amd64", "darwin/arm64"}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := ppitesting.New(New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Cleanup()
+			h.Executor.Handle("go", ppitesting.WriteOptionFile("-o", []byte("binary")))
+
+			err = h.WriteFile("cmd/cli/main.go", []byte("package main\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx := h.AddComponent("acme.org/test", "1.0.0")
+			err = h.Run(idx, &Config{
+				Path:      "cmd/cli",
+				Options:   tc.options,
+				Platforms: tc.platforms,
+				Resource:  Resource{Name: "cli"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			platforms := tc.platforms
+			if len(platforms) == 0 {
+				platforms = []string{""}
+			}
+			cmds := h.Executor.Commands()
+			if len(cmds) != len(platforms) {
+				t.Fatalf("expected %d commands, found %d", len(platforms), len(cmds))
+			}
+			if len(h.Resources(idx)) != len(platforms) {
+				t.Fatalf("expected %d resources, found %d", len(platforms), len(h.Resources(idx)))
+			}
+			for i, pl := range platforms {
+				target := filepath.Join(h.GenDir(), "cli")
+				var extra []string
+				if pl != "" {
+					s := strings.Split(pl, "/")
+					target += "-" + s[0] + "-" + s[1]
+					extra = []string{extraid.ExecutableOperatingSystem, s[0], extraid.ExecutableArchitecture, s[1]}
+				}
+				args := append(append([]string{"go", "build", "-o", target}, tc.options...), filepath.Join(h.BaseDir(), "cmd/cli"))
+				if !reflect.DeepEqual(cmds[i], args) {
+					t.Errorf("expected command %v, found %v", args, cmds[i])
+				}
+
+				r := h.Resource(idx, "cli", extra...)
+				if r == nil {
+					t.Fatalf("resource for platform %q not found", pl)
+				}
+				if r.Type != resourcetypes.EXECUTABLE {
+					t.Errorf("expected type %s, found %s", resourcetypes.EXECUTABLE, r.Type)
+				}
+				path, ok := ppitesting.FileInput(r)
+				if !ok || path != target {
+					t.Errorf("expected file input %s, found %s", target, path)
+				}
+				if data, err := os.ReadFile(target); err != nil || string(data) != "binary" {
+					t.Errorf("executable %s not built", target)
+				}
+			}
+		})
+	}
+}
+
+func TestGoExecutableResource(t *testing.T) {
+	h, err := ppitesting.New(New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Cleanup()
+
+	err = h.WriteFile("main.go", []byte("package main\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := h.AddComponent("acme.org/test", "1.0.0")
+	err = h.Run(idx, `{"path":".","platforms":["linux/amd64"],"resource":{"name":"cli","type":"myexecutable","extraIdentity":{"variant":"static"},"labels":[{"name":"acme.org/label","value":"v"}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := h.Resource(idx, "cli", extraid.ExecutableOperatingSystem, "linux", extraid.ExecutableArchitecture, "amd64", "variant", "static")
+	if r == nil {
+		t.Fatalf("resource not found")
+	}
+	if r.Type != "myexecutable" {
+		t.Errorf("expected type myexecutable, found %s", r.Type)
+	}
+	if len(r.Labels) != 1 || r.Labels[0].Name != "acme.org/label" {
+		t.Errorf("unexpected labels %v", r.Labels)
+	}
+}
+
+func TestGoExecutableErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		index  int
+		config string
+		fail   error
+		err    string
+	}{
+		{"generic step", -1, `{"path":"cmd","resource":{"name":"cli"}}`, nil, "plugin suitable to component build steps, only"},
+		{"no path", 0, `{"resource":{"name":"cli"}}`, nil, "file path to build required"},
+		{"no resource name", 0, `{"path":"cmd"}`, nil, "resource name required"},
+		{"path not found", 0, `{"path":"other","resource":{"name":"cli"}}`, nil, `path "`},
+		{"invalid platform", 0, `{"path":"cmd","platforms":["linux"],"resource":{"name":"cli"}}`, nil, `invalid platform "linux"`},
+		{"build failure", 0, `{"path":"cmd","resource":{"name":"cli"}}`, fmt.Errorf("exit status 1"), "go build failed: exit status 1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := ppitesting.New(New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Cleanup()
+			if tc.fail != nil {
+				h.Executor.Handle("go", ppitesting.Fail(tc.fail))
+			}
+			err = h.WriteFile("cmd/main.go", []byte("package main\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.AddComponent("acme.org/test", "1.0.0")
+
+			err = h.Run(tc.index, tc.config)
+			if err == nil {
+				t.Fatalf("expected error %q", tc.err)
+			}
+			if !strings.HasPrefix(err.Error(), tc.err) {
+				t.Errorf("expected error %q, found %q", tc.err, err)
+			}
+			if len(h.Resources(0)) != 0 {
+				t.Errorf("no resources expected")
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package sbom
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/mandelsoft/ocm-build/utils"
+)
+
+const (
+	MIME_CYCLONEDX = "application/vnd.cyclonedx+json"
+	MIME_SPDX      = "application/spdx+json"
+
+	TOOL = "ocm-build-sbom"
+)
+
+type module struct {
+	path    string
+	version string
+}
+
+func mainModule(name string, info *buildinfo.BuildInfo) module {
+	m := module{path: info.Main.Path, version: info.Main.Version}
+	if m.path == "" {
+		m.path = info.Path
+	}
+	if m.path == "" {
+		m.path = name
+	}
+	return m
+}
+
+func dependencies(info *buildinfo.BuildInfo) []module {
+	var deps []module
+	for _, d := range info.Deps {
+		deps = append(deps, dependency(d))
+	}
+	return deps
+}
+
+func dependency(d *debug.Module) module {
+	if d.Replace != nil {
+		return module{path: d.Replace.Path, version: d.Replace.Version}
+	}
+	return module{path: d.Path, version: d.Version}
+}
+
+func purl(m module) string {
+	if m.version == "" {
+		return "pkg:golang/" + m.path
+	}
+	return "pkg:golang/" + m.path + "@" + m.version
+}
+
+func settings(info *buildinfo.BuildInfo) map[string]string {
+	result := map[string]string{"go.version": info.GoVersion}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "GOOS", "GOARCH", "CGO_ENABLED", "vcs", "vcs.revision", "vcs.time", "vcs.modified":
+			result[s.Key] = s.Value
+		}
+	}
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// CycloneDX
+
+type cdxDocument struct {
+	BOMFormat   string         `json:"bomFormat"`
+	SpecVersion string         `json:"specVersion"`
+	Version     int            `json:"version"`
+	Metadata    cdxMetadata    `json:"metadata"`
+	Components  []cdxComponent `json:"components,omitempty"`
+}
+
+type cdxMetadata struct {
+	Tools      cdxTools      `json:"tools"`
+	Component  cdxComponent  `json:"component"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type    string    `json:"type"`
+	Name    string    `json:"name"`
+	Version string    `json:"version,omitempty"`
+	PURL    string    `json:"purl,omitempty"`
+	Hashes  []cdxHash `json:"hashes,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CycloneDX generates a CycloneDX 1.5 document for a Go executable.
+func CycloneDX(name, digest string, info *buildinfo.BuildInfo) ([]byte, error) {
+	m := mainModule(name, info)
+	doc := cdxDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Tools: cdxTools{Components: []cdxComponent{{Type: "application", Name: TOOL}}},
+			Component: cdxComponent{
+				Type:    "application",
+				Name:    name,
+				Version: m.version,
+				PURL:    purl(m),
+				Hashes:  []cdxHash{{Alg: "SHA-256", Content: digest}},
+			},
+		},
+	}
+	s := settings(info)
+	for _, k := range []string{"go.version", "GOOS", "GOARCH", "CGO_ENABLED", "vcs", "vcs.revision", "vcs.time", "vcs.modified"} {
+		if v, ok := s[k]; ok {
+			doc.Metadata.Properties = append(doc.Metadata.Properties, cdxProperty{Name: k, Value: v})
+		}
+	}
+	for _, d := range dependencies(info) {
+		doc.Components = append(doc.Components, cdxComponent{
+			Type:    "library",
+			Name:    d.path,
+			Version: d.version,
+			PURL:    purl(d),
+		})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// SPDX
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// SPDX generates an SPDX 2.3 document for a Go executable.
+// The creation time is taken from SOURCE_DATE_EPOCH, if set, to
+// keep the document reproducible.
+func SPDX(name, digest string, info *buildinfo.BuildInfo) ([]byte, error) {
+	created, err := creationTime()
+	if err != nil {
+		return nil, err
+	}
+	m := mainModule(name, info)
+	mp := spdxPackage{
+		Name:             name,
+		SPDXID:           "SPDXRef-Package-main",
+		VersionInfo:      m.version,
+		DownloadLocation: "NOASSERTION",
+		Checksums:        []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: digest}},
+		ExternalRefs:     []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: purl(m)}},
+	}
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: fmt.Sprintf("https://ocm.software/spdx/%s-%s", name, digest),
+		CreationInfo: spdxCreationInfo{
+			Created:  created,
+			Creators: []string{"Tool: " + TOOL},
+		},
+		Packages: []spdxPackage{mp},
+		Relationships: []spdxRelationship{
+			{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: mp.SPDXID},
+		},
+	}
+	for i, d := range dependencies(info) {
+		pkg := spdxPackage{
+			Name:             d.path,
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			VersionInfo:      d.version,
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs:     []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: purl(d)}},
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{SPDXElementID: mp.SPDXID, RelationshipType: "DEPENDS_ON", RelatedSPDXElement: pkg.SPDXID})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func creationTime() (string, error) {
+	t, err := utils.SourceDateEpoch(time.Now())
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339), nil
+}
@@ -0,0 +1,125 @@
+package sbom
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"runtime/debug"
+	"testing"
+
+	"github.com/mandelsoft/ocm-build/utils"
+)
+
+func testInfo() *buildinfo.BuildInfo {
+	return &buildinfo.BuildInfo{
+		GoVersion: "go1.22.5",
+		Path:      "acme.org/app/cmd/app",
+		Main:      debug.Module{Path: "acme.org/app", Version: "v1.2.3"},
+		Deps: []*debug.Module{
+			{Path: "github.com/a/lib", Version: "v0.1.0"},
+			{Path: "github.com/b/lib", Version: "v1.0.0", Replace: &debug.Module{Path: "github.com/c/lib", Version: "v1.0.1"}},
+		},
+		Settings: []debug.BuildSetting{
+			{Key: "GOOS", Value: "linux"},
+			{Key: "GOARCH", Value: "amd64"},
+			{Key: "-ldflags", Value: "-s"},
+			{Key: "vcs.revision", Value: "abc"},
+		},
+	}
+}
+
+func TestPURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     *buildinfo.BuildInfo
+		expected string
+	}{
+		{"main module", testInfo(), "pkg:golang/acme.org/app@v1.2.3"},
+		{"no main module", &buildinfo.BuildInfo{Path: "acme.org/app/cmd/app"}, "pkg:golang/acme.org/app/cmd/app"},
+		{"no module information", &buildinfo.BuildInfo{}, "pkg:golang/app"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if r := purl(mainModule("app", tc.info)); r != tc.expected {
+				t.Errorf("expected %q, found %q", tc.expected, r)
+			}
+		})
+	}
+}
+
+func TestCycloneDX(t *testing.T) {
+	data, err := CycloneDX("app", "0123", testInfo())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc cdxDocument
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.BOMFormat != "CycloneDX" || doc.SpecVersion != "1.5" {
+		t.Errorf("unexpected format %s %s", doc.BOMFormat, doc.SpecVersion)
+	}
+	c := doc.Metadata.Component
+	if c.Name != "app" || c.Version != "v1.2.3" || c.PURL != "pkg:golang/acme.org/app@v1.2.3" {
+		t.Errorf("unexpected component %+v", c)
+	}
+	if len(c.Hashes) != 1 || c.Hashes[0].Alg != "SHA-256" || c.Hashes[0].Content != "0123" {
+		t.Errorf("unexpected hashes %+v", c.Hashes)
+	}
+	props := map[string]string{}
+	for _, p := range doc.Metadata.Properties {
+		props[p.Name] = p.Value
+	}
+	if len(props) != 4 || props["go.version"] != "go1.22.5" || props["GOOS"] != "linux" || props["vcs.revision"] != "abc" {
+		t.Errorf("unexpected properties %v", props)
+	}
+	if len(doc.Components) != 2 {
+		t.Fatalf("expected 2 components, found %d", len(doc.Components))
+	}
+	if doc.Components[1].PURL != "pkg:golang/github.com/c/lib@v1.0.1" {
+		t.Errorf("replacement not used: %s", doc.Components[1].PURL)
+	}
+}
+
+func TestSPDX(t *testing.T) {
+	t.Setenv(utils.ENV_SOURCE_DATE_EPOCH, "1700000000")
+
+	data, err := SPDX("app", "0123", testInfo())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc spdxDocument
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || doc.Name != "app" {
+		t.Errorf("unexpected document %s %s", doc.SPDXVersion, doc.Name)
+	}
+	if doc.CreationInfo.Created != "2023-11-14T22:13:20Z" {
+		t.Errorf("SOURCE_DATE_EPOCH not used: %s", doc.CreationInfo.Created)
+	}
+	if len(doc.Packages) != 3 || len(doc.Relationships) != 3 {
+		t.Fatalf("expected 3 packages and relationships, found %d and %d", len(doc.Packages), len(doc.Relationships))
+	}
+	m := doc.Packages[0]
+	if len(m.Checksums) != 1 || m.Checksums[0].Algorithm != "SHA256" || m.Checksums[0].ChecksumValue != "0123" {
+		t.Errorf("unexpected checksums %+v", m.Checksums)
+	}
+	if doc.Relationships[0].RelationshipType != "DESCRIBES" || doc.Relationships[2].RelatedSPDXElement != doc.Packages[2].SPDXID {
+		t.Errorf("unexpected relationships %+v", doc.Relationships)
+	}
+
+	again, err := SPDX("app", "0123", testInfo())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("document not reproducible")
+	}
+
+	t.Setenv(utils.ENV_SOURCE_DATE_EPOCH, "invalid")
+	if _, err := SPDX("app", "0123", testInfo()); err == nil {
+		t.Errorf("invalid SOURCE_DATE_EPOCH not detected")
+	}
+}
@@ -0,0 +1,217 @@
+package sbom
+
+import (
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/mandelsoft/goutils/errors"
+	"github.com/mandelsoft/vfs/pkg/osfs"
+	"github.com/mandelsoft/vfs/pkg/vfs"
+	metav1 "ocm.software/ocm/api/ocm/compdesc/meta/v1"
+	v2 "ocm.software/ocm/api/ocm/compdesc/versions/v2"
+	"ocm.software/ocm/api/utils"
+	"ocm.software/ocm/api/utils/runtime"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/comp"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/rscs"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/inputs"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/inputs/cpi"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/inputs/types/file"
+
+	"github.com/mandelsoft/ocm-build/ppi"
+	"github.com/mandelsoft/ocm-build/state"
+)
+
+const (
+	RESOURCE_TYPE = "sbom"
+	SUBJECT_LABEL = "ocm.software/sbom/subject"
+
+	FORMAT_CYCLONEDX = "cyclonedx"
+	FORMAT_SPDX      = "spdx"
+)
+
+// NAME is the name of the plugin.
+const NAME = "sbom"
+
+// New provides the plugin for the handler.
+func New() *ppi.Plugin[Config] {
+	return ppi.NewPlugin[Config](&Handler{}, usage).SetName(NAME).AddResourceTypes(RESOURCE_TYPE)
+}
+
+type Config struct {
+	Resources []string `json:"resources,omitempty"`
+	Format    string   `json:"format,omitempty"`
+	Suffix    string   `json:"suffix,omitempty"`
+	Type      string   `json:"type,omitempty"`
+}
+
+const usage = `
+- resources (*[]string*) the names of the resources to generate an SBOM for.
+  By default, an SBOM is generated for all resources described by a file
+  input containing a Go executable.
+- format (*string*) the SBOM format: cyclonedx (default) or spdx
+- suffix (*string*) the suffix appended to the subject resource name to
+  get the name of the SBOM resource (default -sbom)
+- type (*string*) the resource type of the SBOM resource (default sbom)
+
+The SBOM resource uses the extra identity of its subject and refers to it
+with the label ` + SUBJECT_LABEL + `.
+`
+
+type Handler struct{}
+
+var _ ppi.Handler[Config] = (*Handler)(nil)
+
+func (h *Handler) Run(p *ppi.Plugin[Config], pstate *state.Descriptor, c *comp.ResourceSpec) error {
+	config := p.Config()
+
+	if config.Format == "" {
+		config.Format = FORMAT_CYCLONEDX
+	}
+	if config.Format != FORMAT_CYCLONEDX && config.Format != FORMAT_SPDX {
+		return fmt.Errorf("unknown SBOM format %q", config.Format)
+	}
+	if config.Suffix == "" {
+		config.Suffix = "-sbom"
+	}
+	typ := utils.OptionalDefaulted(RESOURCE_TYPE, config.Type)
+
+	for _, r := range slices.Clone(c.Resources) {
+		if r.Type == typ {
+			continue
+		}
+		selected := slices.Contains(config.Resources, r.Name)
+		if len(config.Resources) > 0 && !selected {
+			continue
+		}
+		path, ok := state.FileInput(r)
+		if !ok {
+			if selected {
+				return fmt.Errorf("resource %q is not described by a file input", r.Name)
+			}
+			skipped(p, r, "not described by a file input")
+			continue
+		}
+		info, err := buildinfo.ReadFile(path)
+		if err != nil {
+			if selected {
+				return errors.Wrapf(err, "cannot read Go build info of resource %q", r.Name)
+			}
+			skipped(p, r, "no Go executable")
+			continue
+		}
+		err = generate(p, config, c, r, path, info, typ)
+		if err != nil {
+			return errors.Wrapf(err, "resource %q", r.Name)
+		}
+	}
+	return nil
+}
+
+// skipped reports a resource no SBOM is generated for.
+func skipped(p *ppi.Plugin[Config], r *rscs.ResourceSpec, reason string) {
+	p.Info(fmt.Sprintf("skipping resource %s [%s]: %s", r.Name, r.ExtraIdentity.String(), reason), map[string]interface{}{"resource": r.Name, "reason": reason})
+}
+
+func generate(p *ppi.Plugin[Config], cfg *Config, c *comp.ResourceSpec, r *rscs.ResourceSpec, path string, info *buildinfo.BuildInfo, typ string) error {
+	digest, err := fileDigest(path)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	var mediaType string
+	switch cfg.Format {
+	case FORMAT_SPDX:
+		data, err = SPDX(r.Name, digest, info)
+		mediaType = MIME_SPDX
+	default:
+		data, err = CycloneDX(r.Name, digest, info)
+		mediaType = MIME_CYCLONEDX
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot generate SBOM")
+	}
+
+	name := r.Name + cfg.Suffix
+	target := p.GenDir(name)
+	if len(r.ExtraIdentity) > 0 {
+		sum := sha256.Sum256([]byte(r.ExtraIdentity.String()))
+		target += "-" + hex.EncodeToString(sum[:4])
+	}
+	target += ".json"
+	err = os.MkdirAll(vfs.Dir(osfs.OsFs, target), 0o755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(target, data, 0o644)
+	if err != nil {
+		return err
+	}
+
+	subject, err := json.Marshal(map[string]interface{}{
+		"name":          r.Name,
+		"extraIdentity": r.ExtraIdentity,
+		"digest":        map[string]string{"sha256": digest},
+	})
+	if err != nil {
+		return err
+	}
+
+	inp, err := inputs.ToGenericInputSpec(&file.Spec{
+		MediaFileSpec: cpi.MediaFileSpec{
+			PathSpec: cpi.PathSpec{
+				InputSpecBase: inputs.InputSpecBase{
+					ObjectVersionedType: runtime.ObjectVersionedType{
+						Type: file.TYPE,
+					},
+				},
+				Path: target,
+			},
+			ProcessSpec: cpi.ProcessSpec{
+				MediaType: mediaType,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	res := &rscs.ResourceSpec{
+		ElementMeta: v2.ElementMeta{
+			Name:          name,
+			ExtraIdentity: r.ExtraIdentity.Copy(),
+			Labels:        metav1.Labels{{Name: SUBJECT_LABEL, Value: subject}},
+		},
+		Type:     typ,
+		Relation: metav1.LocalRelation,
+		ResourceInput: addhdlrs.ResourceInput{
+			Input: inp,
+		},
+	}
+
+	p.Info(fmt.Sprintf("adding %s SBOM resource %s [%s] for %s", cfg.Format, name, r.ExtraIdentity.String(), r.Name), map[string]interface{}{"resource": name, "subject": r.Name})
+	c.Resources = state.MergeArtifacts(c.Resources, []*rscs.ResourceSpec{res}, "workdir")
+	return nil
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
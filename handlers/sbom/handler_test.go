@@ -0,0 +1,239 @@
+package sbom
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+	metav1 "ocm.software/ocm/api/ocm/compdesc/meta/v1"
+	v2 "ocm.software/ocm/api/ocm/compdesc/versions/v2"
+	"ocm.software/ocm/api/utils/mime"
+	"ocm.software/ocm/api/utils/runtime"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/rscs"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/inputs"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/inputs/cpi"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/inputs/types/file"
+
+	ppitesting "github.com/mandelsoft/ocm-build/ppi/testing"
+)
+
+func fileResource(t *testing.T, name, path string, extra ...string) *rscs.ResourceSpec {
+	inp, err := inputs.ToGenericInputSpec(&file.Spec{
+		MediaFileSpec: cpi.MediaFileSpec{
+			PathSpec: cpi.PathSpec{
+				InputSpecBase: inputs.InputSpecBase{
+					ObjectVersionedType: runtime.ObjectVersionedType{
+						Type: file.TYPE,
+					},
+				},
+				Path: path,
+			},
+			ProcessSpec: cpi.ProcessSpec{
+				MediaType: mime.MIME_OCTET,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &rscs.ResourceSpec{
+		ElementMeta: v2.ElementMeta{
+			Name:          name,
+			ExtraIdentity: metav1.NewExtraIdentity(extra...),
+		},
+		Type:     "executable",
+		Relation: metav1.LocalRelation,
+		ResourceInput: addhdlrs.ResourceInput{
+			Input: inp,
+		},
+	}
+}
+
+// setup provides a harness with a component containing a Go executable
+// (the test binary), a text file and a resource without file input.
+func setup(t *testing.T) (*ppitesting.Harness[Config], int, string) {
+	h, err := ppitesting.New(New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Cleanup() })
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.WriteFile("bin/app", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.WriteFile("docs/readme.txt", []byte("readme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := fileDigest(filepath.Join(h.BaseDir(), "bin/app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := h.AddComponent("acme.org/test", "1.0.0")
+	c := h.Component(idx)
+	c.Resources = append(c.Resources,
+		fileResource(t, "app", filepath.Join(h.BaseDir(), "bin/app"), "os", "linux"),
+		fileResource(t, "doc", filepath.Join(h.BaseDir(), "docs/readme.txt")),
+		&rscs.ResourceSpec{ElementMeta: v2.ElementMeta{Name: "ref"}, Type: "ociImage", Relation: metav1.ExternalRelation},
+	)
+	return h, idx, digest
+}
+
+func TestSBOM(t *testing.T) {
+	tests := []struct {
+		format    string
+		mediaType string
+	}{
+		{"", MIME_CYCLONEDX},
+		{FORMAT_CYCLONEDX, MIME_CYCLONEDX},
+		{FORMAT_SPDX, MIME_SPDX},
+	}
+	for _, tc := range tests {
+		t.Run("format "+tc.format, func(t *testing.T) {
+			h, idx, digest := setup(t)
+
+			err := h.Run(idx, &Config{Format: tc.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(h.Resources(idx)) != 4 {
+				t.Fatalf("expected 4 resources, found %d", len(h.Resources(idx)))
+			}
+			r := h.Resource(idx, "app-sbom", "os", "linux")
+			if r == nil {
+				t.Fatalf("SBOM resource not found")
+			}
+			if r.Type != RESOURCE_TYPE {
+				t.Errorf("expected type %s, found %s", RESOURCE_TYPE, r.Type)
+			}
+			if h.Resource(idx, "doc-sbom") != nil || h.Resource(idx, "ref-sbom") != nil {
+				t.Errorf("unexpected SBOM resources")
+			}
+
+			// subject label
+			if len(r.Labels) != 1 || r.Labels[0].Name != SUBJECT_LABEL {
+				t.Fatalf("subject label not found: %v", r.Labels)
+			}
+			var subject struct {
+				Name          string            `json:"name"`
+				ExtraIdentity map[string]string `json:"extraIdentity"`
+				Digest        map[string]string `json:"digest"`
+			}
+			err = json.Unmarshal(r.Labels[0].Value, &subject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subject.Name != "app" || subject.ExtraIdentity["os"] != "linux" || subject.Digest["sha256"] != digest {
+				t.Errorf("unexpected subject %+v", subject)
+			}
+
+			// generated document
+			path, ok := ppitesting.FileInput(r)
+			if !ok {
+				t.Fatalf("no file input")
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			input, _ := json.Marshal(r.Input)
+			if !strings.Contains(string(input), tc.mediaType) {
+				t.Errorf("media type %s not found in %s", tc.mediaType, string(input))
+			}
+			switch tc.mediaType {
+			case MIME_SPDX:
+				var doc spdxDocument
+				if err := json.Unmarshal(data, &doc); err != nil {
+					t.Fatal(err)
+				}
+				if doc.SPDXVersion != "SPDX-2.3" || len(doc.Packages) == 0 || doc.Packages[0].Checksums[0].ChecksumValue != digest {
+					t.Errorf("unexpected SPDX document %s", string(data))
+				}
+			default:
+				var doc cdxDocument
+				if err := json.Unmarshal(data, &doc); err != nil {
+					t.Fatal(err)
+				}
+				if doc.BOMFormat != "CycloneDX" || doc.Metadata.Component.Name != "app" || doc.Metadata.Component.Hashes[0].Content != digest {
+					t.Errorf("unexpected CycloneDX document %s", string(data))
+				}
+			}
+
+			// skipped resources
+			out := h.Output.String()
+			for _, s := range []string{
+				"skipping resource doc [", "]: no Go executable",
+				"skipping resource ref [", "]: not described by a file input",
+			} {
+				if !strings.Contains(out, s) {
+					t.Errorf("%q not reported:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSBOMOptions(t *testing.T) {
+	h, idx, _ := setup(t)
+
+	config := &Config{Resources: []string{"app"}, Suffix: ".bom", Type: "application/sbom"}
+	for i := 0; i < 2; i++ {
+		err := h.Run(idx, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(h.Resources(idx)) != 4 {
+		t.Fatalf("expected 4 resources, found %d", len(h.Resources(idx)))
+	}
+	r := h.Resource(idx, "app.bom", "os", "linux")
+	if r == nil || r.Type != "application/sbom" {
+		t.Fatalf("SBOM resource not found")
+	}
+	if strings.Contains(h.Output.String(), "skipping") {
+		t.Errorf("unselected resources must not be reported:\n%s", h.Output.String())
+	}
+}
+
+func TestSBOMErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		index  int
+		config string
+		err    string
+	}{
+		{"generic step", -1, `{}`, "plugin suitable to component build steps, only"},
+		{"unknown format", 0, `{"format":"other"}`, `unknown SBOM format "other"`},
+		{"selected non Go file", 0, `{"resources":["doc"]}`, `cannot read Go build info of resource "doc"`},
+		{"selected resource without file input", 0, `{"resources":["ref"]}`, `resource "ref" is not described by a file input`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, _, _ := setup(t)
+
+			err := h.Run(tc.index, tc.config)
+			if err == nil {
+				t.Fatalf("expected error %q", tc.err)
+			}
+			if !strings.HasPrefix(err.Error(), tc.err) {
+				t.Errorf("expected error %q, found %q", tc.err, err)
+			}
+			if len(h.Resources(0)) != 3 {
+				t.Errorf("no SBOM resources expected")
+			}
+		})
+	}
+}
@@ -9,21 +9,33 @@ import (
 	"ocm.software/ocm/api/ocm/extensions/repositories/ctf"
 	utils "ocm.software/ocm/api/ocm/ocmutils"
 	"ocm.software/ocm/api/ocm/plugin/registration"
+	"ocm.software/ocm/api/utils/accessio"
 	"ocm.software/ocm/api/utils/template"
+	"ocm.software/ocm/cmds/ocm/commands/common/options/formatoption"
 
 	"github.com/mandelsoft/ocm-build/build"
+	// register bundled plugins as builtin plugins.
+	_ "github.com/mandelsoft/ocm-build/builtins"
 )
 
 type Options struct {
 	build.Options
 	resolve bool
 	clean   bool
+	verify  bool
+
+	format formatoption.Option
 }
 
 func main() {
 
 	var opts Options
 
+	opts.format.Default = accessio.FormatDirectory
+	opts.Templater = template.Options{
+		Default: "spiff",
+		UseEnv:  false,
+	}
 	cmd := &cobra.Command{
 		Use:   fmt.Sprintf("%s <archive> <buildfile>\n", os.Args[0]),
 		Short: "compose an OCM transport archive from building a project",
@@ -31,12 +43,18 @@ func main() {
 			"providing the resources included into the component version.",
 		Example: "",
 		Version: "0.1.0",
+		Args:    cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return Run(cmd, args, &opts)
 		},
 	}
 
 	cmd.SetArgs(os.Args[1:])
+	pfs := cmd.PersistentFlags()
+	pfs.StringVarP(&opts.GenDir, "gen", "g", "gen", "generation directory")
+	pfs.StringVarP(&opts.PluginDir, "plugins", "p", "", "plugin di")
+	pfs.StringVarP(&opts.BuildFile, "buildfile", "b", "BuildFile.yaml", "build file")
+
 	fs := cmd.Flags()
 
 	fs.BoolVarP(&opts.ReResolve, "reresolve", "r", false, "reresolver plugin identities")
@@ -44,12 +62,22 @@ func main() {
 	fs.BoolVarP(&opts.Force, "force", "f", false, "cleanup existing archive")
 	fs.StringVarP(&opts.Archive, "target", "o", "", "target archive")
 	fs.StringVarP(&opts.Version, "componentVersion", "V", "", "default version")
-	fs.StringVarP(&opts.GenDir, "gen", "g", "gen", "generation directory")
-	fs.StringVarP(&opts.PluginDir, "plugins", "p", "", "plugin di")
-	fs.StringVarP(&opts.BuildFile, "buildfile", "b", "BuildFile.yaml", "build file")
+	fs.IntVarP(&opts.Compression, "compression", "", 0, "gzip compression level for tgz archives (1-9)")
+	fs.BoolVarP(&opts.Reproducible, "reproducible", "", false, "use deterministic file modes and timestamps for tar archives")
+	fs.StringVarP(&opts.UpdateMode, "update-mode", "", build.UPDATE_REPLACE, "handling of existing component versions in archive (replace, skip or fail)")
+	fs.BoolVarP(&opts.Provenance, "provenance", "", false, "attach build provenance labels to generated resources")
+	fs.BoolVarP(&opts.AllowUnsigned, "allow-unsigned", "", false, "accept build plugins without trusted signature")
+	fs.BoolVarP(&opts.AllowUnverified, "allow-unverified", "", false, "accept build plugins whose content cannot be verified")
+	fs.BoolVarP(&opts.Locked, "locked", "", false, "use plugin versions pinned in lock file")
+	fs.BoolVarP(&opts.Offline, "offline", "", false, "resolve plugins only from plugin cache or lock file")
+	fs.BoolVarP(&opts.NoPluginServer, "no-plugin-server", "", false, "start a plugin process for every build step")
+	fs.StringArrayVarP(&opts.Bundles, "plugin-bundle", "", nil, "plugin bundle used as fallback repository")
 
 	fs.BoolVarP(&opts.resolve, "resolve", "", false, "resolve used build plugins")
 	fs.BoolVarP(&opts.clean, "clean", "", false, "clean build state")
+	fs.BoolVarP(&opts.verify, "verify-reproducible", "", false, "build twice and verify reproducibility of the build")
+
+	opts.format.AddFlags(fs)
 
 	err := cmd.Execute()
 	if err != nil {
@@ -59,7 +87,8 @@ func main() {
 
 }
 
-func Run(cmd *cobra.Command, args []string, opts *Options) error {
+// NewContext provides the configured OCM CLI context.
+func NewContext() clictx.Context {
 	ctx := clictx.New()
 
 	_, err := utils.Configure(ctx, "", nil)
@@ -68,12 +97,18 @@ func Run(cmd *cobra.Command, args []string, opts *Options) error {
 		os.Exit(1)
 	}
 	registration.RegisterExtensions(ctx)
-	opts.Format = ctf.FormatDirectory
-	opts.Mode = 0o770
-	opts.Templater = template.Options{
-		Default: "spiff",
-		UseEnv:  false,
+	return ctx
+}
+
+func Run(cmd *cobra.Command, args []string, opts *Options) error {
+	ctx := NewContext()
+
+	err := opts.format.Configure(ctx)
+	if err != nil {
+		return err
 	}
+	opts.Format = ctf.GetFormat(opts.format.Format)
+	opts.Mode = opts.format.Mode()
 
 	if len(args) > 0 {
 		opts.Components = args
@@ -85,6 +120,9 @@ func Run(cmd *cobra.Command, args []string, opts *Options) error {
 	if opts.resolve {
 		return build.Resolve(ctx, opts.Options)
 	}
+	if opts.verify {
+		return build.VerifyReproducible(ctx, opts.Options)
+	}
 	return build.Execute(ctx, opts.Options)
 }
 
@@ -45,6 +45,7 @@ type command struct {
 	opts    build.Options
 	resolve bool
 	clean   bool
+	verify  bool
 
 	template templateroption.Option
 	format   formatoption.Option
@@ -58,9 +59,20 @@ func (c *command) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVarP(&c.opts.GenDir, "gen", "g", "gen", "generation directory")
 	fs.StringVarP(&c.opts.PluginDir, "plugins", "p", "", "plugin di")
 	fs.StringVarP(&c.opts.BuildFile, "buildfile", "b", "BuildFile.yaml", "build file")
+	fs.IntVarP(&c.opts.Compression, "compression", "", 0, "gzip compression level for tgz archives (1-9)")
+	fs.BoolVarP(&c.opts.Reproducible, "reproducible", "", false, "use deterministic file modes and timestamps for tar archives")
+	fs.StringVarP(&c.opts.UpdateMode, "update-mode", "", build.UPDATE_REPLACE, "handling of existing component versions in archive (replace, skip or fail)")
+	fs.BoolVarP(&c.opts.Provenance, "provenance", "", false, "attach build provenance labels to generated resources")
+	fs.BoolVarP(&c.opts.AllowUnsigned, "allow-unsigned", "", false, "accept build plugins without trusted signature")
+	fs.BoolVarP(&c.opts.AllowUnverified, "allow-unverified", "", false, "accept build plugins whose content cannot be verified")
+	fs.BoolVarP(&c.opts.Locked, "locked", "", false, "use plugin versions pinned in lock file")
+	fs.BoolVarP(&c.opts.Offline, "offline", "", false, "resolve plugins only from plugin cache or lock file")
+	fs.BoolVarP(&c.opts.NoPluginServer, "no-plugin-server", "", false, "start a plugin process for every build step")
+	fs.StringArrayVarP(&c.opts.Bundles, "plugin-bundle", "", nil, "plugin bundle used as fallback repository")
 
 	fs.BoolVarP(&c.resolve, "resolve", "", false, "resolve used build plugins")
 	fs.BoolVarP(&c.clean, "clean", "", false, "clean build state")
+	fs.BoolVarP(&c.verify, "verify-reproducible", "", false, "build twice and verify reproducibility of the build")
 
 	c.template.AddFlags(fs)
 	c.format.AddFlags(fs)
@@ -99,5 +111,8 @@ func (c *command) Run(cmd *cobra.Command, args []string) error {
 	if c.resolve {
 		return build.Resolve(cctx, c.opts)
 	}
+	if c.verify {
+		return build.VerifyReproducible(cctx, c.opts)
+	}
 	return build.Execute(cctx, c.opts)
 }
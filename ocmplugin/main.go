@@ -12,6 +12,8 @@ import (
 	"ocm.software/ocm/api/ocm/plugin/ppi/cmds"
 	"ocm.software/ocm/api/version"
 
+	// register bundled plugins as builtin plugins.
+	_ "github.com/mandelsoft/ocm-build/builtins"
 	"github.com/mandelsoft/ocm-build/ocmplugin/cmds/build"
 )
 
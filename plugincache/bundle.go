@@ -0,0 +1,85 @@
+package plugincache
+
+import (
+	"encoding/json"
+
+	"github.com/mandelsoft/goutils/errors"
+	"ocm.software/ocm/api/ocm"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+)
+
+// Import installs the build plugins of all component versions found in
+// a repository, for example a plugin bundle. Component versions without
+// build plugin resources are ignored.
+func (o *PluginCache) Import(repo ocm.Repository) ([]Info, error) {
+	sess := ocm.NewSession(nil)
+	defer sess.Close()
+
+	data, err := json.Marshal(repo.GetSpecification())
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal repository spec")
+	}
+	raw := json.RawMessage(data)
+
+	lister := repo.ComponentLister()
+	if lister == nil {
+		return nil, errors.Newf("repository does not support listing components")
+	}
+	names, err := lister.GetComponents("", true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list components")
+	}
+
+	var result []Info
+	list := errors.ErrListf("import")
+	for _, n := range names {
+		c, err := sess.LookupComponent(repo, n)
+		if err != nil {
+			list.Add(err)
+			continue
+		}
+		vers, err := c.ListVersions()
+		if err != nil {
+			list.Add(errors.Wrapf(err, "cannot list versions for component %s", n))
+			continue
+		}
+		for _, v := range vers {
+			cv, err := sess.GetComponentVersion(c, v)
+			if err != nil {
+				list.Add(err)
+				continue
+			}
+			if !hasPlugin(cv) {
+				continue
+			}
+			info := &Info{
+				Spec: buildfile.Plugin{
+					Repository: &raw,
+					Component:  n,
+					Version:    v,
+				},
+				Id: HashId{
+					Component: n,
+					Version:   v,
+				},
+			}
+			p, err := o.download(sess, cv, "", info)
+			if err != nil {
+				list.Add(err)
+				continue
+			}
+			result = append(result, p.info)
+		}
+	}
+	return result, list.Result()
+}
+
+func hasPlugin(cv ocm.ComponentVersionAccess) bool {
+	for _, r := range cv.GetResources() {
+		if r.Meta().Type == RESOURCE_TYPE {
+			return true
+		}
+	}
+	return false
+}
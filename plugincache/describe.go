@@ -0,0 +1,76 @@
+package plugincache
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"github.com/mandelsoft/ocm-build/ppi"
+)
+
+// Describe provides the descriptor of a plugin. For cached plugins it is
+// recorded in the info file. Plugins not supporting the option --describe
+// provide no descriptor (nil). Executables are only described, if
+// requested by their plugin specification.
+func (o *PluginCache) Describe(p *Plugin) (*ppi.Descriptor, error) {
+	if p.info.Descriptor != nil {
+		return p.info.Descriptor, nil
+	}
+	if p.nodescribe {
+		return nil, nil
+	}
+	key := strings.Join(append([]string{p.path}, p.baseargs...), " ")
+	if d, ok := o.descriptors[key]; ok {
+		return d, nil
+	}
+
+	d := describe(p)
+	o.descriptors[key] = d
+	if d != nil && p.Info() != nil {
+		p.info.Descriptor = d
+		for i := range o.plugins {
+			if o.plugins[i].path == p.path {
+				o.plugins[i].Descriptor = d
+			}
+		}
+		err := o.recordDescriptor(p, d)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// recordDescriptor stores the descriptor in the info file of the cache
+// entry. The entry may be shared, therefore only the descriptor is
+// updated on the actual content of the info file.
+func (o *PluginCache) recordDescriptor(p *Plugin, d *ppi.Descriptor) error {
+	unlock, err := o.lock(p.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	info, err := loadInfo(p.path)
+	if err != nil || info.Digest != p.info.Digest {
+		// entry removed or replaced in the meantime
+		return nil
+	}
+	info.Descriptor = d
+	return writeInfo(p.path, info)
+}
+
+func describe(p *Plugin) *ppi.Descriptor {
+	out := bytes.NewBuffer(nil)
+	cmd := exec.Command(p.path, p.Args("--describe")...)
+	cmd.Stdout = out
+	if cmd.Run() != nil {
+		return nil
+	}
+	var d ppi.Descriptor
+	if json.Unmarshal(out.Bytes(), &d) != nil || d.Protocol == "" {
+		return nil
+	}
+	return &d
+}
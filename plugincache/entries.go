@@ -0,0 +1,83 @@
+package plugincache
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+)
+
+// Entries provides the entries found in the plugin cache.
+func (o *PluginCache) Entries() []Entry {
+	return append([]Entry{}, o.plugins...)
+}
+
+// Lookup provides the cache entries matching the given id.
+// The id is either a (prefix of a) cache hash or a component
+// optionally followed by a version (<component>[:<version>]).
+func (o *PluginCache) Lookup(id string) []Entry {
+	var result []Entry
+	comp, vers, _ := strings.Cut(id, ":")
+	for _, e := range o.plugins {
+		if strings.HasPrefix(e.Hash(), id) ||
+			(e.Id.Component == comp && (vers == "" || e.Id.Version == vers)) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Remove removes an entry from the plugin cache.
+func (o *PluginCache) Remove(e *Entry) error {
+	unlock, err := o.lock(e.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = os.Remove(e.path + ".info")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Remove(e.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// waiting lockers detect the removal and use a new lock file.
+	os.Remove(e.path + ".lock")
+	o.forget(e.path)
+	return nil
+}
+
+func (e *Entry) Path() string {
+	return e.path
+}
+
+// Hash provides the cache key of the entry.
+func (e *Entry) Hash() string {
+	return filepath.Base(e.path)
+}
+
+// Size provides the size of the cached plugin executable.
+func (e *Entry) Size() int64 {
+	fi, err := os.Stat(e.path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// LastUsedTime provides the last usage time of the entry.
+// For entries without recorded usage, the modification time
+// of the info file is used.
+func (e *Entry) LastUsedTime() time.Time {
+	if !e.LastUsed.IsZero() {
+		return e.LastUsed
+	}
+	fi, err := os.Stat(e.path + ".info")
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package plugincache
+
+// flock is not supported on this platform. Installations
+// still use atomic renames.
+func flock(path string) (func(), error) {
+	return func() {}, nil
+}
+
+// tryFlock is not supported on this platform. The lock is
+// always granted.
+func tryFlock(path string) (func(), bool, error) {
+	return func() {}, true, nil
+}
@@ -0,0 +1,59 @@
+//go:build unix
+
+package plugincache
+
+import (
+	"os"
+	"syscall"
+)
+
+// flock acquires an exclusive file lock for the given path.
+// It blocks until the lock is available.
+func flock(path string) (func(), error) {
+	unlock, _, err := lockFile(path, syscall.LOCK_EX)
+	return unlock, err
+}
+
+// tryFlock acquires an exclusive file lock for the given path
+// without blocking. If the lock is held by someone else, false
+// is returned.
+func tryFlock(path string) (func(), bool, error) {
+	return lockFile(path, syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func lockFile(path string, how int) (func(), bool, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, false, err
+		}
+		err = syscall.Flock(int(f.Fd()), how)
+		if err != nil {
+			f.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		unlock := func() {
+			syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+			f.Close()
+		}
+
+		// the lock file is removed together with its cache entry,
+		// so it might have been replaced while waiting for the lock.
+		fi, err := f.Stat()
+		if err != nil {
+			unlock()
+			return nil, false, err
+		}
+		cur, err := os.Stat(path)
+		if err == nil && os.SameFile(fi, cur) {
+			return unlock, true, nil
+		}
+		unlock()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, false, err
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package plugincache
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+	"github.com/mandelsoft/goutils/errors"
+	"github.com/mandelsoft/vfs/pkg/vfs"
+	"ocm.software/ocm/api/utils/runtime"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+)
+
+const LOCK_VERSION = "v1"
+
+// Lock describes the pinned resolutions of plugin specifications.
+type Lock struct {
+	Version string      `json:"version"`
+	Plugins []LockEntry `json:"plugins"`
+}
+
+type LockEntry struct {
+	Spec      buildfile.Plugin `json:"spec"`
+	Component string           `json:"component"`
+	Version   string           `json:"version"`
+	Resource  string           `json:"resource"`
+	Digest    string           `json:"digest,omitempty"`
+}
+
+// LockFile provides the path of the lock file for a build file.
+func LockFile(buildfile string) string {
+	return strings.TrimSuffix(buildfile, filepath.Ext(buildfile)) + ".lock"
+}
+
+// ReadLock reads a lock file. If it does not exist, nil is returned.
+func ReadLock(fs vfs.FileSystem, path string) (*Lock, error) {
+	if ok, err := vfs.FileExists(fs, path); !ok || err != nil {
+		return nil, err
+	}
+	data, err := vfs.ReadFile(fs, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read lock file %q", path)
+	}
+	var lock Lock
+	err = runtime.DefaultYAMLEncoding.Unmarshal(data, &lock)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot decode lock file %q", path)
+	}
+	if lock.Version != LOCK_VERSION {
+		return nil, errors.Newf("unsupported lock file version %q", lock.Version)
+	}
+	return &lock, nil
+}
+
+func NewLock() *Lock {
+	return &Lock{Version: LOCK_VERSION}
+}
+
+func (l *Lock) Write(fs vfs.FileSystem, path string) error {
+	data, err := runtime.DefaultYAMLEncoding.Marshal(l)
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal lock file")
+	}
+	err = vfs.WriteFile(fs, path, data, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "cannot write lock file %q", path)
+	}
+	return nil
+}
+
+// Lookup provides the lock entry for a plugin specification.
+func (l *Lock) Lookup(spec *buildfile.Plugin) *LockEntry {
+	if l == nil {
+		return nil
+	}
+	for i := range l.Plugins {
+		if reflect.DeepEqual(&l.Plugins[i].Spec, spec) {
+			return &l.Plugins[i]
+		}
+	}
+	return nil
+}
+
+// Add adds or updates the lock entry for a resolved plugin.
+func (l *Lock) Add(info *Info) {
+	e := LockEntry{
+		Spec:      info.Spec,
+		Component: info.Id.Component,
+		Version:   info.Id.Version,
+		Resource:  info.Id.Resource,
+		Digest:    info.Digest,
+	}
+	if old := l.Lookup(&info.Spec); old != nil {
+		*old = e
+	} else {
+		l.Plugins = append(l.Plugins, e)
+	}
+}
@@ -0,0 +1,174 @@
+package plugincache
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mandelsoft/vfs/pkg/memoryfs"
+	"github.com/mandelsoft/vfs/pkg/vfs"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+)
+
+func raw(s string) *json.RawMessage {
+	r := json.RawMessage(s)
+	return &r
+}
+
+func TestLockFile(t *testing.T) {
+	tests := []struct {
+		buildfile string
+		expected  string
+	}{
+		{"BuildFile.yaml", "BuildFile.lock"},
+		{"dir/BuildFile.yml", "dir/BuildFile.lock"},
+		{"dir.d/build", "dir.d/build.lock"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.buildfile, func(t *testing.T) {
+			if r := LockFile(tc.buildfile); r != tc.expected {
+				t.Errorf("expected %q, found %q", tc.expected, r)
+			}
+		})
+	}
+}
+
+func TestLockLookup(t *testing.T) {
+	ref := buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/plugin"}
+	repo := buildfile.Plugin{Repository: raw(`{"baseUrl":"ghcr.io","type":"OCIRegistry"}`), Component: "acme.org/plugin", Version: ">=1.0"}
+	lock := &Lock{
+		Version: LOCK_VERSION,
+		Plugins: []LockEntry{
+			{Spec: ref, Component: "acme.org/plugin", Version: "1.0.0", Resource: "plugin"},
+			{Spec: repo, Component: "acme.org/plugin", Version: "1.2.0", Resource: "plugin"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		spec    buildfile.Plugin
+		version string
+	}{
+		{"reference", buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/plugin"}, "1.0.0"},
+		{"repository with equal content", buildfile.Plugin{Repository: raw(`{"baseUrl":"ghcr.io","type":"OCIRegistry"}`), Component: "acme.org/plugin", Version: ">=1.0"}, "1.2.0"},
+		{"other reference", buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/other"}, ""},
+		{"additional field", buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/plugin", Resource: "plugin"}, ""},
+		{"other constraint", buildfile.Plugin{Repository: raw(`{"baseUrl":"ghcr.io","type":"OCIRegistry"}`), Component: "acme.org/plugin", Version: ">=1.1"}, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := lock.Lookup(&tc.spec)
+			switch {
+			case tc.version == "" && e != nil:
+				t.Errorf("unexpected entry %s", e.Version)
+			case tc.version != "" && e == nil:
+				t.Errorf("entry not found")
+			case e != nil && e.Version != tc.version:
+				t.Errorf("expected version %s, found %s", tc.version, e.Version)
+			}
+		})
+	}
+
+	var none *Lock
+	if none.Lookup(&ref) != nil {
+		t.Errorf("nil lock must not provide entries")
+	}
+}
+
+func TestMatchesLock(t *testing.T) {
+	spec := buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/plugin"}
+	id := HashId{Component: "acme.org/plugin", Version: "1.0.0", Resource: "plugin"}
+	lock := &Lock{
+		Version: LOCK_VERSION,
+		Plugins: []LockEntry{
+			{Spec: spec, Component: id.Component, Version: id.Version, Resource: id.Resource, Digest: "d1"},
+			{Spec: buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/other"}, Component: "acme.org/other", Version: "2.0.0", Resource: "other"},
+		},
+	}
+	o := &PluginCache{opts: Options{Lock: lock}}
+
+	tests := []struct {
+		name     string
+		spec     buildfile.Plugin
+		info     Info
+		expected bool
+	}{
+		{"matching", spec, Info{Id: id, Digest: "d1"}, true},
+		{"digest mismatch", spec, Info{Id: id, Digest: "d2"}, false},
+		{"version mismatch", spec, Info{Id: HashId{Component: id.Component, Version: "1.1.0", Resource: id.Resource}, Digest: "d1"}, false},
+		{"no locked digest", buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/other"}, Info{Id: HashId{Component: "acme.org/other", Version: "2.0.0", Resource: "other"}, Digest: "d3"}, true},
+		{"not locked", buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/unknown"}, Info{Id: id, Digest: "d2"}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if r := o.matchesLock(&tc.spec, &tc.info); r != tc.expected {
+				t.Errorf("expected %t, found %t", tc.expected, r)
+			}
+		})
+	}
+}
+
+func TestLockAdd(t *testing.T) {
+	spec := buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/plugin"}
+	lock := NewLock()
+
+	lock.Add(&Info{Spec: spec, Id: HashId{Component: "acme.org/plugin", Version: "1.0.0", Resource: "plugin"}, Digest: "d1"})
+	lock.Add(&Info{Spec: buildfile.Plugin{PluginRef: "ghcr.io/acme//acme.org/other"}, Id: HashId{Component: "acme.org/other", Version: "2.0.0", Resource: "other"}})
+	lock.Add(&Info{Spec: spec, Id: HashId{Component: "acme.org/plugin", Version: "1.1.0", Resource: "plugin"}, Digest: "d2"})
+
+	if len(lock.Plugins) != 2 {
+		t.Fatalf("expected 2 entries, found %d", len(lock.Plugins))
+	}
+	e := lock.Lookup(&spec)
+	if e == nil || e.Version != "1.1.0" || e.Digest != "d2" {
+		t.Errorf("entry not updated: %+v", e)
+	}
+	if lock.Plugins[0].Version != "1.1.0" {
+		t.Errorf("entry order not kept")
+	}
+}
+
+func TestLockReadWrite(t *testing.T) {
+	fs := memoryfs.New()
+
+	l, err := ReadLock(fs, "/BuildFile.lock")
+	if l != nil || err != nil {
+		t.Fatalf("missing lock file: expected nil, found %v, %v", l, err)
+	}
+
+	lock := NewLock()
+	lock.Add(&Info{
+		Spec:   buildfile.Plugin{Repository: raw(`{"baseUrl":"ghcr.io","type":"OCIRegistry"}`), Component: "acme.org/plugin"},
+		Id:     HashId{Component: "acme.org/plugin", Version: "1.0.0", Resource: "plugin"},
+		Digest: "digest",
+	})
+	err = lock.Write(fs, "/BuildFile.lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err = ReadLock(fs, "/BuildFile.lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Plugins) != 1 || l.Plugins[0].Digest != "digest" {
+		t.Fatalf("unexpected lock content: %+v", l)
+	}
+	if l.Lookup(&lock.Plugins[0].Spec) == nil {
+		t.Errorf("spec not found after reading lock file")
+	}
+
+	err = vfs.WriteFile(fs, "/other.lock", []byte("version: v0\nplugins: []\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadLock(fs, "/other.lock"); err == nil {
+		t.Errorf("unsupported version not detected")
+	}
+	err = vfs.WriteFile(fs, "/invalid.lock", []byte("version: [\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadLock(fs, "/invalid.lock"); err == nil {
+		t.Errorf("invalid lock file not detected")
+	}
+}
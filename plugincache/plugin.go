@@ -5,11 +5,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"reflect"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
@@ -22,11 +22,11 @@ import (
 	"ocm.software/ocm/api/ocm"
 	"ocm.software/ocm/api/ocm/extensions/download"
 	"ocm.software/ocm/api/ocm/extraid"
-	"ocm.software/ocm/api/utils"
 	common "ocm.software/ocm/api/utils/misc"
 	"ocm.software/ocm/api/utils/semverutils"
 
 	"github.com/mandelsoft/ocm-build/buildfile"
+	"github.com/mandelsoft/ocm-build/ppi"
 )
 
 const RESOURCE_TYPE = "ocm.software/buildplugin"
@@ -37,14 +37,46 @@ func init() {
 	anyVersion, _ = semver.NewConstraint("*")
 }
 
+// Options describes optional settings for a plugin cache.
+type Options struct {
+	// Cached reuses cached resolutions instead of reresolving
+	// plugin references.
+	Cached bool
+	// AllowUnsigned accepts plugins without trusted signature.
+	AllowUnsigned bool
+	// AllowUnverified accepts plugins whose content cannot be verified
+	// against the digest found in the component descriptor.
+	AllowUnverified bool
+	// Signatures restricts the signatures accepted for plugins.
+	Signatures []string
+	// PublicKeys provides public keys or certificates for signature names.
+	PublicKeys map[string][]byte
+	// Lock provides pinned resolutions for plugin specifications.
+	Lock *Lock
+	// Locked enforces the usage of the pinned resolutions.
+	Locked bool
+	// Offline resolves plugins only from the cache or the lock
+	// without accessing any repository.
+	Offline bool
+	// Mirrors are alternative repositories for all plugin references.
+	Mirrors []buildfile.Mirror
+	// Fallbacks are repositories (for example plugin bundles) tried,
+	// if a plugin cannot be resolved from its repository or mirrors.
+	Fallbacks []ocm.RepositorySpec
+}
+
 type PluginCache struct {
 	ctx       ocm.Context
 	path      string
 	printer   common.Printer
 	reresolve bool
+	opts      Options
 
-	discovered []buildfile.Plugin
-	plugins    []Entry
+	discovered  []buildfile.Plugin
+	plugins     []Entry
+	resolved    []Info
+	sources     map[string]*Plugin
+	descriptors map[string]*ppi.Descriptor
 }
 
 type Entry struct {
@@ -53,15 +85,46 @@ type Entry struct {
 }
 
 type Info struct {
-	Id     HashId           `json:"id"`
-	Spec   buildfile.Plugin `json:"spec"`
-	Digest string           `json:"digest"`
+	Id   HashId           `json:"id"`
+	Spec buildfile.Plugin `json:"spec"`
+	// Specs are further plugin specifications resolved to
+	// this entry, for example by other projects.
+	Specs      []buildfile.Plugin `json:"specs,omitempty"`
+	Digest     string             `json:"digest"`
+	FileDigest string             `json:"fileDigest,omitempty"`
+	LastUsed   time.Time          `json:"lastUsed"`
+	// Repository is the specification of the repository
+	// the plugin has been downloaded from.
+	Repository json.RawMessage `json:"repository,omitempty"`
+	// Descriptor is the descriptor provided by the plugin.
+	Descriptor *ppi.Descriptor `json:"descriptor,omitempty"`
+	// Trust describes the signature verification done when the plugin
+	// was installed. It is nil, if the plugin has never been verified.
+	Trust *Trust `json:"trust,omitempty"`
+}
+
+// Matches checks whether a plugin specification has been
+// resolved to the cache entry.
+func (i *Info) Matches(spec *buildfile.Plugin) bool {
+	if reflect.DeepEqual(&i.Spec, spec) {
+		return true
+	}
+	for j := range i.Specs {
+		if reflect.DeepEqual(&i.Specs[j], spec) {
+			return true
+		}
+	}
+	return false
 }
 
 type Plugin struct {
 	path     string
 	baseargs []string
 	desc     string
+	builtin  bool
+	// nodescribe disables the --describe request for
+	// executables not known to be based on the ppi package.
+	nodescribe bool
 
 	info Info
 }
@@ -74,11 +137,52 @@ func (p *Plugin) Args(args ...string) []string {
 	return append(append([]string{}, p.baseargs...), args...)
 }
 
+// Info provides the cache information for plugins provided
+// by the plugin cache. For executables nil is returned.
+func (p *Plugin) Info() *Info {
+	if p.info.Id.Component == "" {
+		return nil
+	}
+	return &p.info
+}
+
+// NewPlugin provides a plugin for an executable not
+// managed by the plugin cache.
+func NewPlugin(path, desc string, baseargs ...string) *Plugin {
+	return &Plugin{
+		path:     path,
+		desc:     desc,
+		baseargs: baseargs,
+	}
+}
+
+// NewBuiltinPlugin provides a plugin for a handler linked
+// into the build binary.
+func NewBuiltinPlugin(name string, d *ppi.Descriptor) *Plugin {
+	return &Plugin{
+		path:    name,
+		desc:    "builtin",
+		builtin: true,
+		info:    Info{Descriptor: d},
+	}
+}
+
+// IsBuiltin checks whether the plugin is executed in-process.
+// For builtin plugins the path is the plugin name.
+func (p *Plugin) IsBuiltin() bool {
+	return p.builtin
+}
+
 func (p *Plugin) String() string {
 	return fmt.Sprintf("%s[%s]", p.desc, vfs.Base(osfs.OsFs, p.path))
 }
 
-func New(ctx ocm.Context, path string, printer common.Printer, cached ...bool) (*PluginCache, error) {
+func New(ctx ocm.Context, path string, printer common.Printer, opts ...*Options) (*PluginCache, error) {
+	var o Options
+	if opt := general.Optional(opts...); opt != nil {
+		o = *opt
+	}
+
 	err := os.MkdirAll(path, 0o750)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot create build plugin dir")
@@ -95,6 +199,10 @@ func New(ctx ocm.Context, path string, printer common.Printer, cached ...bool) (
 			var info Info
 			pp := vfs.Join(osfs.OsFs, path, strings.TrimSuffix(e.Name(), ".info"))
 			d, err := os.ReadFile(vfs.Join(osfs.OsFs, path, e.Name()))
+			if os.IsNotExist(err) {
+				// concurrently removed from shared cache
+				continue
+			}
 			if err != nil {
 				return nil, errors.Wrapf(err, "cannot read plugin info")
 			}
@@ -103,20 +211,22 @@ func New(ctx ocm.Context, path string, printer common.Printer, cached ...bool) (
 				if ok, err := vfs.FileExists(osfs.OsFs, pp); ok && err == nil {
 					plugins = append(plugins, Entry{info, pp})
 				} else {
-					os.Remove(e.Name())
-					printer.Printf("WARNING: no ülgin found for %s in filesystem", e.Name(), err)
+					printer.Printf("WARNING: no plugin found for %s in filesystem\n", e.Name())
 				}
 			} else {
-				printer.Printf("WARNING: cannot unmarshal plugin info for %s", e.Name(), err)
+				printer.Printf("WARNING: cannot unmarshal plugin info for %s: %s\n", e.Name(), err)
 			}
 		}
 	}
 	return &PluginCache{
-		ctx:       ctx,
-		path:      path,
-		printer:   printer,
-		plugins:   plugins,
-		reresolve: !general.Optional(cached...),
+		ctx:         ctx,
+		path:        path,
+		printer:     printer,
+		plugins:     plugins,
+		reresolve:   !o.Cached && !o.Offline,
+		sources:     map[string]*Plugin{},
+		descriptors: map[string]*ppi.Descriptor{},
+		opts:        o,
 	}, nil
 }
 
@@ -155,22 +265,149 @@ func (o *PluginCache) getPath(hid *HashId) string {
 	return filepath.Join(o.path, Hash(hid))
 }
 
-func (o *PluginCache) add(info *Info, path string) *Plugin {
-	o.discovered = append(o.discovered, info.Spec)
+func (o *PluginCache) Options() *Options {
+	return &o.opts
+}
+
+// Resolved provides the resolutions of all repository based plugin
+// specifications requested from the cache.
+func (o *PluginCache) Resolved() []Info {
+	return o.resolved
+}
+
+// add registers the resolution of a plugin specification. Because the
+// cache may be shared, the specifications and the repository recorded
+// for an existing entry are kept.
+func (o *PluginCache) add(info *Info, path string) (*Plugin, error) {
+	unlock, err := o.lock(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return o.addLocked(info, path)
+}
+
+// addLocked is like add, but the caller must hold the lock
+// of the cache entry.
+func (o *PluginCache) addLocked(info *Info, path string) (*Plugin, error) {
+	info.LastUsed = time.Now().UTC()
+	entry := *info
+	if cached, err := loadInfo(path); err == nil && cached.Digest == info.Digest {
+		entry.Spec = cached.Spec
+		entry.Specs = cached.Specs
+		if cached.Repository != nil {
+			entry.Repository = cached.Repository
+		}
+		if !entry.Matches(&info.Spec) {
+			entry.Specs = append(entry.Specs, info.Spec)
+		}
+	}
+	err := writeInfo(path, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if !o.isResolved(info) {
+		o.discovered = append(o.discovered, info.Spec)
+		o.resolved = append(o.resolved, *info)
+	}
+	o.forget(path)
 	o.plugins = append(o.plugins, Entry{
-		Info: *info,
+		Info: entry,
 		path: path,
 	})
 	return &Plugin{
 		path: path,
 		desc: info.Id.String(),
 		info: *info,
+	}, nil
+}
+
+// isResolved checks whether a resolution has already been recorded.
+func (o *PluginCache) isResolved(info *Info) bool {
+	for _, r := range o.resolved {
+		if r.Id == info.Id && reflect.DeepEqual(&r.Spec, &info.Spec) {
+			return true
+		}
 	}
+	return false
+}
+
+// lock acquires the lock for a cache entry.
+func (o *PluginCache) lock(path string) (func(), error) {
+	unlock, err := flock(path + ".lock")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot lock plugin cache entry %s", filepath.Base(path))
+	}
+	return unlock, nil
+}
+
+// tryLock acquires the lock of a cache entry without blocking.
+// If the entry is locked by someone else, false is returned.
+func (o *PluginCache) tryLock(path string) (func(), bool, error) {
+	unlock, ok, err := tryFlock(path + ".lock")
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "cannot lock plugin cache entry %s", filepath.Base(path))
+	}
+	return unlock, ok, nil
+}
+
+// writeInfo atomically replaces the info file of a cache entry.
+func writeInfo(path string, info *Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal plugin info")
+	}
+	err = writeFile(path+".info", data, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "cannot write plugin info file")
+	}
+	return nil
+}
+
+// writeFile writes a file by renaming a temporary file
+// in the same directory.
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
 }
 
 func (o *PluginCache) Get(pspec *buildfile.Plugin, dir string) (*Plugin, error) {
 	base := &utils2.BasePath{dir}
 
+	if pspec.Local != nil {
+		return nil, fmt.Errorf("local plugins are provided by the build")
+	}
+	if pspec.Builtin != "" {
+		return nil, fmt.Errorf("builtin plugins are provided by the build")
+	}
+
+	if pspec.Describe && pspec.Executable == nil {
+		return nil, fmt.Errorf("describe only possible for executables")
+	}
+
+	if pspec.Source != nil {
+		if pspec.PluginRef != "" || pspec.Repository != nil || pspec.Component != "" || pspec.Version != "" || pspec.Resource != "" || pspec.Executable != nil {
+			return nil, fmt.Errorf("for a source plugin no other plugin specification is possible")
+		}
+		return o.getSource(pspec.Source, base)
+	}
+
 	if pspec.Executable != nil {
 		if pspec.PluginRef != "" {
 			return nil, fmt.Errorf("for an execuable no reference required")
@@ -193,9 +430,10 @@ func (o *PluginCache) Get(pspec *buildfile.Plugin, dir string) (*Plugin, error)
 			return nil, errors.Wrapf(err, "invalid executable")
 		}
 		return &Plugin{
-			path:     args[0],
-			desc:     "executable",
-			baseargs: args[1:],
+			path:       args[0],
+			desc:       "executable",
+			baseargs:   args[1:],
+			nodescribe: !pspec.Describe,
 		}, nil
 	}
 
@@ -220,21 +458,36 @@ func (o *PluginCache) Get(pspec *buildfile.Plugin, dir string) (*Plugin, error)
 		}
 	}
 
+	// set, if a cached plugin has not been verified with
+	// the actual trust settings.
+	var trustErr error
+
 	// if assumed to be resolved, lookup cache entry
 	if discovered {
-		for _, pi := range o.plugins {
-			if reflect.DeepEqual(&pi.Spec, pspec) {
+		// latest resolutions are added last
+		for i := len(o.plugins) - 1; i >= 0; i-- {
+			pi := o.plugins[i]
+			if pi.Matches(pspec) && o.matchesLock(pspec, &pi.Info) {
 				// o.printer.Printf("using cached plugin\n")
-				return &Plugin{
-					path: pi.path,
-					desc: pi.Info.Id.String(),
-				}, nil
+				if err := pi.Verify(); err != nil {
+					o.invalidate(pi.path, err)
+					break
+				}
+				if err := o.Trusted(&pi.Info); err != nil {
+					// re-verify signature
+					trustErr = err
+					break
+				}
+				// record the resolution for the lock file
+				info := pi.Info
+				info.Spec = *pspec
+				return o.add(&info, pi.path)
 			}
 		}
 	} else {
 		for i, pi := range o.plugins {
 			// forget cached resolution for yet undiscovered entry
-			if reflect.DeepEqual(&pi.Spec, pspec) {
+			if pi.Matches(pspec) {
 				// reevaluate ref
 				o.plugins = append(o.plugins[:i], o.plugins[i+1:]...)
 				break
@@ -319,24 +572,186 @@ func (o *PluginCache) Get(pspec *buildfile.Plugin, dir string) (*Plugin, error)
 		}
 	}
 
+	if o.opts.Locked {
+		entry := o.opts.Lock.Lookup(pspec)
+		if entry == nil {
+			return nil, fmt.Errorf("plugin not found in lock file (resolve required)")
+		}
+		info.Id = HashId{
+			Component: entry.Component,
+			Version:   entry.Version,
+			Resource:  entry.Resource,
+		}
+		info.Digest = entry.Digest
+	}
+
+	if o.opts.Offline && !info.Id.IsComplete() {
+		if entry := o.opts.Lock.Lookup(pspec); entry != nil {
+			info.Id = HashId{
+				Component: entry.Component,
+				Version:   entry.Version,
+				Resource:  entry.Resource,
+			}
+			info.Digest = entry.Digest
+		} else if cached := o.lookupCached(&info.Id); cached != nil {
+			info.Id = cached.Id
+		}
+	}
+
 	if info.Id.IsComplete() {
 		path := o.getPath(&info.Id)
 		if ok, err := vfs.FileExists(osfs.OsFs, path); ok && err == nil {
 			// o.printer.Printf("resusing completely specified ref\n")
-			return o.add(&info, path), nil
+			cached, err := readInfo(path)
+			if err == nil && info.Digest != "" && cached.Digest != info.Digest {
+				err = fmt.Errorf("digest %s of cached plugin %s does not match lock file", cached.Digest, info.Id.String())
+			}
+			if err == nil {
+				if terr := o.Trusted(&cached.Info); terr != nil {
+					// re-verify signature with download
+					trustErr = terr
+				} else {
+					info.Digest = cached.Digest
+					info.FileDigest = cached.FileDigest
+					info.Repository = cached.Repository
+					info.Descriptor = cached.Descriptor
+					info.Trust = cached.Trust
+					return o.add(&info, path)
+				}
+			} else {
+				o.invalidate(path, err)
+			}
 		}
 	}
 
+	if o.opts.Offline {
+		if trustErr != nil {
+			return nil, errors.Wrapf(trustErr, "plugin %s cannot be verified offline", info.Id.String())
+		}
+		return nil, fmt.Errorf("plugin %s not found in plugin cache", info.Id.String())
+	}
+
 	// New resolution for plugin spec
 	sess := ocm.NewSession(nil)
 	defer sess.Close()
 
-	repo, err := sess.LookupRepository(o.ctx, repospec)
+	repos, primary, err := o.repositories(pspec, repospec)
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) == 1 {
+		return o.downloadFrom(sess, repospec, &info)
+	}
+	list := errors.ErrListf("cannot resolve plugin %s", info.Id.String())
+	for i, spec := range repos {
+		p, err := o.downloadFrom(sess, spec, &info)
+		if err == nil {
+			if i != primary {
+				o.printer.Printf("plugin %s served by %s\n", p.info.Id.String(), string(p.info.Repository))
+			}
+			return p, nil
+		}
+		list.Add(err)
+	}
+	return nil, list.Result()
+}
+
+// repositories provides the repositories to try for a plugin specification
+// in order: the preferred mirrors, the primary repository, the other mirrors
+// and finally the fallback repositories. Additionally, the index of the
+// primary repository is returned.
+func (o *PluginCache) repositories(pspec *buildfile.Plugin, primary ocm.RepositorySpec) ([]ocm.RepositorySpec, int, error) {
+	var preferred, other []ocm.RepositorySpec
+
+	mirrors := append(append([]buildfile.Mirror{}, pspec.Mirrors...), o.opts.Mirrors...)
+	for i, m := range mirrors {
+		spec, err := o.mirrorSpec(&m)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid mirror %d", i+1)
+		}
+		if m.Prefer {
+			preferred = append(preferred, spec)
+		} else {
+			other = append(other, spec)
+		}
+	}
+	result := append(preferred, primary)
+	result = append(result, other...)
+	return append(result, o.opts.Fallbacks...), len(preferred), nil
+}
+
+func (o *PluginCache) mirrorSpec(m *buildfile.Mirror) (ocm.RepositorySpec, error) {
+	var raw interface{}
+	err := json.Unmarshal(m.Repository, &raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse repository spec")
+	}
+	if s, ok := raw.(string); ok {
+		spec, err := ocm.ParseRepo(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid repository spec")
+		}
+		return o.ctx.MapUniformRepositorySpec(&spec)
+	}
+	return o.ctx.RepositorySpecForConfig(m.Repository, nil)
+}
+
+// downloadFrom resolves a plugin from the given repository.
+func (o *PluginCache) downloadFrom(sess ocm.Session, spec ocm.RepositorySpec, info *Info) (*Plugin, error) {
+	i := *info
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal repository spec")
+	}
+	i.Repository = data
+
+	repo, err := sess.LookupRepository(o.ctx, spec)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get repository")
 	}
+	return o.DownloadFromRepo(sess, repo, i.Id.Component, i.Id.Version, &i)
+}
+
+// matchesLock checks whether a resolution complies with the
+// lock file entry for a plugin specification.
+func (o *PluginCache) matchesLock(pspec *buildfile.Plugin, info *Info) bool {
+	entry := o.opts.Lock.Lookup(pspec)
+	if entry == nil {
+		return true
+	}
+	id := &info.Id
+	return entry.Component == id.Component && entry.Version == id.Version && entry.Resource == id.Resource &&
+		(entry.Digest == "" || entry.Digest == info.Digest)
+}
+
+// lookupCached provides the cache entry with the highest version
+// matching a (partial) plugin identity.
+func (o *PluginCache) lookupCached(id *HashId) *Entry {
+	constraints := anyVersion
+	if id.Version != "" {
+		c, err := semver.NewConstraint(id.Version)
+		if err != nil {
+			return nil
+		}
+		constraints = c
+	}
 
-	return o.DownloadFromRepo(sess, repo, info.Id.Component, info.Id.Version, &info)
+	var found *Entry
+	var foundVersion *semver.Version
+	for i, e := range o.plugins {
+		if e.Id.Component != id.Component || (id.Resource != "" && e.Id.Resource != id.Resource) {
+			continue
+		}
+		v, err := semver.NewVersion(e.Id.Version)
+		if err != nil || !constraints.Check(v) {
+			continue
+		}
+		if found == nil || v.GreaterThan(foundVersion) {
+			found = &o.plugins[i]
+			foundVersion = v
+		}
+	}
+	return found
 }
 
 func (o *PluginCache) DownloadFromRepo(session ocm.Session, repo ocm.Repository, comp, vers string, info *Info) (*Plugin, error) {
@@ -398,6 +813,12 @@ func (o *PluginCache) downloadLatest(session ocm.Session, comp ocm.ComponentAcce
 func (o *PluginCache) download(session ocm.Session, cv ocm.ComponentVersionAccess, name string, info *Info) (p *Plugin, err error) {
 	defer errors.PropagateErrorf(&err, nil, "%s", common.VersionedElementKey(cv))
 
+	trust, err := o.VerifySignature(cv)
+	if err != nil {
+		return nil, err
+	}
+	info.Trust = trust
+
 	var found ocm.ResourceAccess
 	var wrong ocm.ResourceAccess
 
@@ -438,69 +859,83 @@ func (o *PluginCache) download(session ocm.Session, cv ocm.ComponentVersionAcces
 	}
 
 	target := o.getPath(hid)
-	infofile := target + ".info"
+
+	digest := ""
+	if found.Meta().Digest != nil {
+		digest = found.Meta().Digest.Value
+	}
+	if info.Digest != "" && info.Digest != digest {
+		return nil, fmt.Errorf("digest %s of resource %q does not match locked digest %s", digest, found.Meta().Name, info.Digest)
+	}
+
+	// the cache may be shared among concurrent builds.
+	unlock, err := o.lock(target)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	fs := osfs.New()
 	if ok, _ := vfs.FileExists(fs, target); ok {
-		var info Info
-		d, err := os.ReadFile(infofile)
+		cached, err := readInfo(target)
 		if err == nil {
-			err = json.Unmarshal(d, &info)
-			if err != nil {
-				return nil, err
-			}
-			if err == nil && info.Digest == found.Meta().Digest.Value {
-				return o.add(&info, target), nil
+			if cached.Digest == digest {
+				i := cached.Info
+				i.Spec = info.Spec
+				i.Repository = info.Repository
+				i.Trust = trust
+				return o.addLocked(&i, target)
 			}
+		} else {
+			o.remove(target, err)
 		}
 	}
 
 	printer := o.printer.AddGap("    ")
 	d := ""
-	if found.Meta().Digest != nil {
-		d = "{" + found.Meta().Digest.Value + "}"
+	if digest != "" {
+		d = "{" + digest + "}"
 	}
 	printer.Printf("found build plugin resource %s[%s:%s]%s\n", name, cv.GetName(), cv.GetVersion(), d)
-	file, err := os.CreateTemp(os.TempDir(), "plugin-*")
+
+	// download into the cache directory to be able to atomically
+	// rename the verified file.
+	file, err := os.CreateTemp(o.path, "."+filepath.Base(target)+".*")
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot create temp file")
 	}
 	file.Close()
+	defer os.Remove(file.Name())
 
 	_, _, err = download.For(o.ctx).DownloadAsBlob(printer.AddGap("  "), found, file.Name(), fs)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot download resource %s", found.Meta().Name)
 	}
 
-	printer.Printf("installing build plugin %s[%s:%s] in %s...\n", name, hid.Component, hid.Version, o.path)
-	dst, err := fs.OpenFile(target, vfs.O_CREATE|vfs.O_TRUNC|vfs.O_WRONLY, 0o755)
+	ok, err := VerifyBlob(file.Name(), found.Meta().Digest)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot create plugin file %s", target)
+		return nil, errors.Wrapf(err, "verification of resource %s failed", found.Meta().Name)
 	}
-	src, err := fs.OpenFile(file.Name(), vfs.O_RDONLY, 0)
-	if err != nil {
-		dst.Close()
-		return nil, errors.Wrapf(err, "cannot open plugin executable %s", file.Name())
+	if !ok {
+		if !o.opts.AllowUnverified {
+			return nil, fmt.Errorf("content of resource %s cannot be verified: unsupported digest %s (use --allow-unverified to accept it)", found.Meta().Name, digestDesc(found.Meta().Digest))
+		}
+		printer.Printf("WARNING: accepting unverified content of resource %s\n", found.Meta().Name)
 	}
-	_, err = io.Copy(dst, src)
-	utils.IgnoreError(dst.Close())
-	utils.IgnoreError(src.Close())
-	utils.IgnoreError(os.Remove(file.Name()))
+	info.FileDigest, err = FileDigest(file.Name())
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot copy plugin file %s", target)
+		return nil, errors.Wrapf(err, "cannot digest resource %s", found.Meta().Name)
 	}
-	if found.Meta().Digest != nil {
-		info.Digest = found.Meta().Digest.Value
-		d, err := json.Marshal(info)
-		if err != nil {
-			return nil, errors.Wrapf(err, "cannot marshal plugin info")
-		}
-		err = os.WriteFile(infofile, d, 0o644)
-		if err != nil {
-			return nil, errors.Wrapf(err, "cannot write plugin info file")
-		}
-	} else {
-		os.Remove(infofile)
+
+	printer.Printf("installing build plugin %s[%s:%s] in %s...\n", name, hid.Component, hid.Version, o.path)
+	err = os.Chmod(file.Name(), 0o755)
+	if err == nil {
+		err = os.Rename(file.Name(), target)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot install plugin file %s", target)
 	}
-	return o.add(info, target), nil
+	info.Id = *hid
+	info.Digest = digest
+	return o.addLocked(info, target)
 }
@@ -0,0 +1,116 @@
+package plugincache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/mandelsoft/goutils/errors"
+	"ocm.software/ocm/api/ocm"
+	"ocm.software/ocm/api/ocm/tools/signing"
+)
+
+// Trust describes the signature verification of a plugin.
+type Trust struct {
+	// Signature is the name of the verified signature. It is empty, if
+	// the plugin has been accepted without trusted signature.
+	Signature string `json:"signature,omitempty"`
+	// KeyDigest is the digest of the public key configured for the
+	// signature. It is empty, if the key has been taken from the
+	// OCM configuration.
+	KeyDigest string `json:"keyDigest,omitempty"`
+}
+
+// VerifySignature checks whether the plugin component version is signed
+// by a trusted signature. If signature names are configured, one of those
+// signatures must be valid, otherwise any signature found in the
+// component descriptor is accepted, if it can be verified with the
+// configured keys or the keys and certificates found in the OCM
+// configuration. Unsigned or untrusted component versions are only
+// accepted with option AllowUnsigned.
+func (o *PluginCache) VerifySignature(cv ocm.ComponentVersionAccess) (*Trust, error) {
+	trust, err := o.verifySignature(cv)
+	if err != nil {
+		if o.opts.AllowUnsigned {
+			o.printer.Printf("WARNING: accepting untrusted plugin: %s\n", err)
+			return &Trust{}, nil
+		}
+		return nil, errors.Wrapf(err, "untrusted build plugin (use --allow-unsigned to accept it)")
+	}
+	return trust, nil
+}
+
+// Trusted checks whether the signature verification recorded for a
+// cached plugin is valid for the actual trust settings. Because the
+// cache is shared, the plugin might have been installed with other
+// settings.
+func (o *PluginCache) Trusted(info *Info) error {
+	t := info.Trust
+	if t == nil {
+		return fmt.Errorf("plugin %s has not been verified", info.Id.String())
+	}
+	if t.Signature == "" {
+		if o.opts.AllowUnsigned {
+			return nil
+		}
+		return fmt.Errorf("plugin %s has been accepted without trusted signature", info.Id.String())
+	}
+	if len(o.opts.Signatures) > 0 && !slices.Contains(o.opts.Signatures, t.Signature) {
+		return fmt.Errorf("signature %q of plugin %s is not accepted", t.Signature, info.Id.String())
+	}
+	if keyDigest(o.opts.PublicKeys[t.Signature]) != t.KeyDigest {
+		return fmt.Errorf("plugin %s has been verified with another key for signature %q", info.Id.String(), t.Signature)
+	}
+	return nil
+}
+
+func keyDigest(key []byte) string {
+	if key == nil {
+		return ""
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+func (o *PluginCache) verifySignature(cv ocm.ComponentVersionAccess) (*Trust, error) {
+	signatures := cv.GetDescriptor().Signatures
+	if len(signatures) == 0 {
+		return nil, fmt.Errorf("component version is not signed")
+	}
+
+	names := o.opts.Signatures
+	if len(names) == 0 {
+		for _, s := range signatures {
+			names = append(names, s.Name)
+		}
+	}
+
+	list := errors.ErrListf("signature verification failed")
+	for _, n := range names {
+		found := false
+		for _, s := range signatures {
+			if s.Name == n {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list.Add(fmt.Errorf("signature %q not found", n))
+			continue
+		}
+
+		sopts := []signing.Option{signing.Resolver(cv.Repository())}
+		key, ok := o.opts.PublicKeys[n]
+		if ok {
+			sopts = append(sopts, signing.PublicKey(n, key))
+		}
+		_, err := signing.VerifyComponentVersion(cv, n, sopts...)
+		if err == nil {
+			o.printer.AddGap("    ").Printf("signature %q verified\n", n)
+			return &Trust{Signature: n, KeyDigest: keyDigest(key)}, nil
+		}
+		list.Add(errors.Wrapf(err, "signature %q", n))
+	}
+	return nil, list.Result()
+}
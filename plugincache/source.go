@@ -0,0 +1,248 @@
+package plugincache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+	"github.com/mandelsoft/goutils/errors"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+	"github.com/mandelsoft/ocm-build/utils"
+)
+
+const SOURCE_PREFIX = "src-"
+
+// getSource provides a plugin built from Go sources. The executable is
+// built once into the plugin cache and rebuilt only if the sources of the
+// module (Go files, go.mod and go.sum) or the Go toolchain change.
+func (o *PluginCache) getSource(spec *buildfile.SourcePlugin, base *utils.BasePath) (*Plugin, error) {
+	if spec.GoPackagePath == "" {
+		return nil, fmt.Errorf("gopkgpath required for source plugin")
+	}
+	pkg, err := filepath.Abs(base.Path(spec.GoPackagePath))
+	if err != nil {
+		return nil, err
+	}
+
+	module := ""
+	if spec.Module != "" {
+		module, err = filepath.Abs(base.Path(spec.Module))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		module, err = findModule(pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p := o.sources[module+"\n"+pkg]; p != nil {
+		return p, nil
+	}
+	rel, err := filepath.Rel(module, pkg)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("package %q not in module %q", pkg, module)
+	}
+
+	version, err := goVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s/%s\n%s\n", rel, runtime.GOOS, runtime.GOARCH, version)
+	err = hashModule(h, module, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot hash sources of %q", module)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	key := sha256.Sum256([]byte(module + "\n" + pkg))
+	prefix := SOURCE_PREFIX + hex.EncodeToString(key[:8]) + "-"
+	target := filepath.Join(o.path, prefix+digest)
+
+	unlock, err := o.lock(target)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cached, err := readInfo(target)
+	if err != nil {
+		err = o.buildSource(module, rel, target)
+		if err != nil {
+			return nil, err
+		}
+		o.cleanupSources(prefix, target)
+		cached = &Entry{
+			Info: Info{
+				Id: HashId{
+					Component: pkg,
+					Version:   digest[:12],
+				},
+				Spec: buildfile.Plugin{Source: spec},
+			},
+			path: target,
+		}
+		cached.FileDigest, err = FileDigest(target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot digest plugin %s", target)
+		}
+	} else if !cached.Matches(&buildfile.Plugin{Source: spec}) {
+		cached.Specs = append(cached.Specs, buildfile.Plugin{Source: spec})
+	}
+
+	// register the build as cache entry to be visible for
+	// listing and pruning.
+	cached.LastUsed = time.Now().UTC()
+	err = writeInfo(target, &cached.Info)
+	if err != nil {
+		return nil, err
+	}
+	o.forget(target)
+	o.plugins = append(o.plugins, *cached)
+
+	p := &Plugin{
+		path: target,
+		desc: "source " + spec.GoPackagePath,
+	}
+	o.sources[module+"\n"+pkg] = p
+	return p, nil
+}
+
+var goversion struct {
+	sync.Once
+	version string
+	err     error
+}
+
+// goVersion provides the version of the Go toolchain used to
+// build source plugins.
+func goVersion() (string, error) {
+	goversion.Do(func() {
+		out, err := exec.Command("go", "env", "GOVERSION").Output()
+		if err != nil {
+			goversion.err = errors.Wrapf(err, "cannot determine go version")
+			return
+		}
+		goversion.version = strings.TrimSpace(string(out))
+	})
+	return goversion.version, goversion.err
+}
+
+func (o *PluginCache) buildSource(module, pkg, target string) error {
+	tmp, err := os.CreateTemp(o.path, "."+filepath.Base(target)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "cannot create temp file")
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	o.printer.Printf("building plugin %s in %s...\n", pkg, module)
+	cmd := exec.Command("go", "build", "-o", tmp.Name(), "."+string(os.PathSeparator)+pkg)
+	cmd.Dir = module
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return errors.Wrapf(err, "cannot build plugin %s", pkg)
+	}
+	err = os.Rename(tmp.Name(), target)
+	if err != nil {
+		return errors.Wrapf(err, "cannot install plugin %s", target)
+	}
+	return nil
+}
+
+// cleanupSources removes outdated builds of a source plugin
+// for the same module and package.
+func (o *PluginCache) cleanupSources(prefix, target string) {
+	entries, err := os.ReadDir(o.path)
+	if err != nil {
+		return
+	}
+	paths := map[string]bool{}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) {
+			name := strings.TrimSuffix(strings.TrimSuffix(e.Name(), ".info"), ".lock")
+			paths[filepath.Join(o.path, name)] = true
+		}
+	}
+	for path := range paths {
+		if path == target {
+			continue
+		}
+		// builds still in use by concurrent builds are kept
+		// and left to pruning.
+		unlock, ok, err := o.tryLock(path)
+		if err != nil || !ok {
+			continue
+		}
+		os.Remove(path + ".info")
+		os.Remove(path)
+		// waiting lockers detect the removal and use a new lock file.
+		os.Remove(path + ".lock")
+		unlock()
+		o.forget(path)
+	}
+}
+
+func findModule(dir string) (string, error) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d, nil
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("no go module found for %q", dir)
+		}
+	}
+}
+
+// hashModule hashes the Go sources, go.mod and go.sum of a module,
+// ignoring tests, hidden directories and nested modules.
+func hashModule(h hash.Hash, module, rel string) error {
+	entries, err := os.ReadDir(filepath.Join(module, rel))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		path := filepath.Join(rel, name)
+		if e.IsDir() {
+			if strings.HasPrefix(name, ".") || name == "testdata" {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(module, path, "go.mod")); err == nil {
+				continue
+			}
+			err = hashModule(h, module, path)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if (strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")) ||
+			(rel == "" && (name == "go.mod" || name == "go.sum")) {
+			f, err := os.Open(filepath.Join(module, path))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\n", path)
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
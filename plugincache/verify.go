@@ -0,0 +1,145 @@
+package plugincache
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/mandelsoft/goutils/errors"
+	metav1 "ocm.software/ocm/api/ocm/compdesc/meta/v1"
+)
+
+const GENERIC_BLOB_DIGEST = "genericBlobDigest/v1"
+
+// FileDigest calculates the SHA-256 digest of a file.
+func FileDigest(path string) (string, error) {
+	return fileDigest(path, sha256.New())
+}
+
+func fileDigest(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyBlob checks the content of a downloaded plugin file against the
+// digest of the resource found in the component descriptor.
+// It returns false, if the digest cannot be verified, because it is
+// missing or uses an unsupported normalization or hash algorithm.
+func VerifyBlob(path string, digest *metav1.DigestSpec) (bool, error) {
+	if digest == nil || digest.NormalisationAlgorithm != GENERIC_BLOB_DIGEST {
+		return false, nil
+	}
+	var h hash.Hash
+	switch digest.HashAlgorithm {
+	case "SHA-256", "sha256":
+		h = sha256.New()
+	case "SHA-512", "sha512":
+		h = sha512.New()
+	default:
+		return false, nil
+	}
+	d, err := fileDigest(path, h)
+	if err != nil {
+		return false, err
+	}
+	if d != digest.Value {
+		return false, fmt.Errorf("digest mismatch: expected %s, found %s", digest.Value, d)
+	}
+	return true, nil
+}
+
+func digestDesc(digest *metav1.DigestSpec) string {
+	if digest == nil {
+		return "<none>"
+	}
+	return fmt.Sprintf("%s/%s", digest.NormalisationAlgorithm, digest.HashAlgorithm)
+}
+
+// Verify checks the cached plugin executable against the
+// digest recorded when it was installed.
+func (e *Entry) Verify() error {
+	if e.FileDigest == "" {
+		return fmt.Errorf("no digest recorded for plugin %s", e.Id.String())
+	}
+	d, err := FileDigest(e.path)
+	if err != nil {
+		return errors.Wrapf(err, "cannot verify plugin %s", e.Id.String())
+	}
+	if d != e.FileDigest {
+		return fmt.Errorf("plugin %s modified: expected digest %s, found %s", e.Id.String(), e.FileDigest, d)
+	}
+	return nil
+}
+
+// readInfo reads and verifies the cache entry for
+// the given plugin path.
+func readInfo(path string) (*Entry, error) {
+	info, err := loadInfo(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{Info: *info, path: path}
+	return e, e.Verify()
+}
+
+// loadInfo reads the info file of a cache entry without
+// verifying the plugin executable.
+func loadInfo(path string) (*Info, error) {
+	d, err := os.ReadFile(path + ".info")
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	err = json.Unmarshal(d, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// invalidate removes a corrupted cache entry. Because the cache may be
+// shared, the entry is verified again under the entry lock, it might
+// have been reinstalled concurrently.
+func (o *PluginCache) invalidate(path string, err error) {
+	unlock, lerr := o.lock(path)
+	if lerr != nil {
+		o.printer.Printf("WARNING: %s\n", lerr)
+		return
+	}
+	defer unlock()
+	if _, verr := readInfo(path); verr == nil {
+		return
+	}
+	o.remove(path, err)
+}
+
+// remove removes a cache entry. The entry lock must be held.
+func (o *PluginCache) remove(path string, err error) {
+	o.printer.Printf("WARNING: %s -> removing cached plugin\n", err)
+	os.Remove(path + ".info")
+	os.Remove(path)
+	o.forget(path)
+}
+
+func (o *PluginCache) forget(path string) {
+	for i, pi := range o.plugins {
+		if pi.path == path {
+			o.plugins = append(o.plugins[:i], o.plugins[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mandelsoft/ocm-build/handlers/sbom"
+)
+
+func main() {
+	sbom.New().Run(os.Args)
+}
@@ -0,0 +1,56 @@
+package ppi
+
+const (
+	PROTOCOL_V1 = "v1"
+
+	KIND_GENERIC   = "generic"
+	KIND_COMPONENT = "component"
+)
+
+// Descriptor describes a build plugin. It is provided by
+// calling a plugin with the option --describe.
+type Descriptor struct {
+	Name string `json:"name"`
+	// Protocol is the preferred protocol version.
+	Protocol string `json:"protocol"`
+	// Protocols lists all supported protocol versions.
+	Protocols []string `json:"protocols,omitempty"`
+	// Server indicates the support of the server mode (option --serve).
+	Server bool `json:"server,omitempty"`
+	// Kinds lists the supported step kinds
+	// (KIND_GENERIC and/or KIND_COMPONENT).
+	Kinds []string `json:"kinds"`
+	// ConfigSchema is a JSON schema for the step config.
+	ConfigSchema Schema `json:"configSchema,omitempty"`
+	// ResourceTypes lists the types of the resources
+	// generated by the plugin.
+	ResourceTypes []string `json:"resourceTypes,omitempty"`
+}
+
+// Supports checks whether the plugin can be used for generic (index < 0)
+// or component build steps.
+func (d *Descriptor) Supports(index int) bool {
+	kind := KIND_COMPONENT
+	if index < 0 {
+		kind = KIND_GENERIC
+	}
+	for _, k := range d.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsProtocol checks whether the plugin supports a protocol version.
+func (d *Descriptor) SupportsProtocol(v string) bool {
+	if d.Protocol == v {
+		return true
+	}
+	for _, p := range d.Protocols {
+		if p == v {
+			return true
+		}
+	}
+	return false
+}
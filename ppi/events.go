@@ -0,0 +1,103 @@
+package ppi
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// ENV_EVENTS provides the file descriptor of the event channel.
+	ENV_EVENTS = "OCM_BUILD_EVENTS"
+
+	EVENT_INFO     = "info"
+	EVENT_WARNING  = "warning"
+	EVENT_ERROR    = "error"
+	EVENT_PROGRESS = "progress"
+)
+
+// Event is a structured progress or log event emitted by a plugin.
+// Events are sent as JSON lines.
+type Event struct {
+	Kind    string                 `json:"kind"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Time    time.Time              `json:"time"`
+}
+
+var (
+	eventsLock sync.Mutex
+	events     *os.File
+	eventsInit bool
+)
+
+func eventChannel() *os.File {
+	if !eventsInit {
+		eventsInit = true
+		if fd, err := strconv.Atoi(os.Getenv(ENV_EVENTS)); err == nil && fd > 2 {
+			closeOnExec(fd)
+			events = os.NewFile(uintptr(fd), "events")
+		}
+	}
+	return events
+}
+
+// Event emits a structured event. In server mode it is sent as
+// notification on the RPC channel. If the engine does not provide an
+// event channel, the event is written to the plugin printer.
+func (p *Plugin[C]) Event(kind, msg string, fields map[string]interface{}) {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+
+	ev := &Event{
+		Kind:    kind,
+		Message: msg,
+		Fields:  fields,
+		Time:    time.Now().UTC(),
+	}
+	if p.events != nil {
+		if p.events(ev) == nil {
+			return
+		}
+	} else if f := eventChannel(); f != nil {
+		data, err := json.Marshal(ev)
+		if err == nil {
+			_, err = f.Write(append(data, '\n'))
+		}
+		if err == nil {
+			return
+		}
+	}
+	if kind == EVENT_INFO || kind == EVENT_PROGRESS {
+		p.printer.Printf("%s\n", msg)
+	} else {
+		p.printer.Printf("%s: %s\n", kind, msg)
+	}
+}
+
+func (p *Plugin[C]) Info(msg string, fields ...map[string]interface{}) {
+	p.Event(EVENT_INFO, msg, mergeFields(fields))
+}
+
+func (p *Plugin[C]) Warning(msg string, fields ...map[string]interface{}) {
+	p.Event(EVENT_WARNING, msg, mergeFields(fields))
+}
+
+func (p *Plugin[C]) Progress(msg string, fields ...map[string]interface{}) {
+	p.Event(EVENT_PROGRESS, msg, mergeFields(fields))
+}
+
+func mergeFields(fields []map[string]interface{}) map[string]interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	result := map[string]interface{}{}
+	for _, f := range fields {
+		for k, v := range f {
+			result[k] = v
+		}
+	}
+	return result
+}
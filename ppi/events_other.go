@@ -0,0 +1,8 @@
+//go:build !unix
+
+package ppi
+
+// closeOnExec is not required on this platform, the engine
+// provides no event channel.
+func closeOnExec(fd int) {
+}
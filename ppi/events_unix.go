@@ -0,0 +1,13 @@
+//go:build unix
+
+package ppi
+
+import (
+	"syscall"
+)
+
+// closeOnExec prevents the event channel from being inherited
+// by commands executed by the plugin.
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
@@ -0,0 +1,23 @@
+package ppi
+
+import (
+	"os/exec"
+)
+
+// Executor executes external commands. It can be replaced
+// to test handlers without running the commands.
+type Executor interface {
+	Execute(cmd *exec.Cmd) error
+}
+
+// ExecutorFunc is a function implementing the Executor interface.
+type ExecutorFunc func(cmd *exec.Cmd) error
+
+func (f ExecutorFunc) Execute(cmd *exec.Cmd) error {
+	return f(cmd)
+}
+
+// DefaultExecutor just runs the command.
+var DefaultExecutor Executor = ExecutorFunc(func(cmd *exec.Cmd) error {
+	return cmd.Run()
+})
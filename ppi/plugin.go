@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -20,6 +22,16 @@ type Handler[C any] interface {
 	Run(p *Plugin[C], pstate *state.Descriptor, c *comp.ResourceSpec) error
 }
 
+// Processor executes build steps in-process. It is implemented
+// by Plugin.
+type Processor interface {
+	Descriptor() *Descriptor
+	SetOutput(printer common.Printer, events func(ev *Event) error)
+	Process(env *state.Environment, config json.RawMessage, pstate *state.Descriptor, index int) error
+}
+
+var _ Processor = (*Plugin[any])(nil)
+
 type Plugin[C any] struct {
 	comp    bool
 	handler Handler[C]
@@ -27,6 +39,11 @@ type Plugin[C any] struct {
 	printer common.Printer
 	env     state.Environment
 	usage   string
+
+	name          string
+	resourceTypes []string
+	executor      Executor
+	events        func(ev *Event) error
 }
 
 func NewPlugin[C any](h Handler[C], usage ...string) *Plugin[C] {
@@ -37,6 +54,85 @@ func NewGenericPlugin[C any](h Handler[C], usage ...string) *Plugin[C] {
 	return &Plugin[C]{comp: false, handler: h, printer: common.StderrPrinter.AddGap("      "), usage: strings.Join(usage, "\n")}
 }
 
+// SetName sets the plugin name used in the plugin descriptor.
+// By default, the name of the executable is used.
+func (p *Plugin[C]) SetName(name string) *Plugin[C] {
+	p.name = name
+	return p
+}
+
+// AddResourceTypes declares the types of the resources
+// generated by the plugin.
+func (p *Plugin[C]) AddResourceTypes(types ...string) *Plugin[C] {
+	p.resourceTypes = append(p.resourceTypes, types...)
+	return p
+}
+
+// SetExecutor sets the executor used to execute external commands.
+func (p *Plugin[C]) SetExecutor(e Executor) *Plugin[C] {
+	p.executor = e
+	return p
+}
+
+// SetPrinter sets the printer used for regular output.
+func (p *Plugin[C]) SetPrinter(printer common.Printer) *Plugin[C] {
+	p.printer = printer
+	return p
+}
+
+// SetOutput sets the printer and the event handler used for
+// in-process execution. Without event handler, events are
+// written to the printer.
+func (p *Plugin[C]) SetOutput(printer common.Printer, events func(ev *Event) error) {
+	p.printer = printer
+	p.events = events
+}
+
+// Execute executes an external command using the configured executor.
+func (p *Plugin[C]) Execute(cmd *exec.Cmd) error {
+	if p.executor == nil {
+		return DefaultExecutor.Execute(cmd)
+	}
+	return p.executor.Execute(cmd)
+}
+
+// Process executes the handler in-process for the given environment,
+// config and state. The state is modified in place. A negative index
+// describes a generic build step.
+func (p *Plugin[C]) Process(env *state.Environment, config json.RawMessage, pstate *state.Descriptor, index int) error {
+	p.env = *env
+	var c C
+	p.config = c
+	if len(config) > 0 {
+		err := json.Unmarshal(config, &p.config)
+		if err != nil {
+			return fmt.Errorf("cannot parse config: %w", err)
+		}
+	}
+	return p.process(pstate, index)
+}
+
+// Descriptor provides the plugin descriptor.
+func (p *Plugin[C]) Descriptor() *Descriptor {
+	name := p.name
+	if name == "" {
+		name = vfs.Base(osfs.OsFs, os.Args[0])
+	}
+	kinds := []string{KIND_COMPONENT}
+	if !p.comp {
+		kinds = []string{KIND_GENERIC, KIND_COMPONENT}
+	}
+	return &Descriptor{
+		Name:          name,
+		Protocol:      PROTOCOL_V2,
+		Protocols:     []string{PROTOCOL_V1, PROTOCOL_V2},
+		Server:        true,
+		Kinds:         kinds,
+		ConfigSchema:  SchemaFor(reflect.TypeOf(&p.config).Elem()),
+		ResourceTypes: p.resourceTypes,
+	}
+}
+
 func (p *Plugin[C]) Printer() common.Printer {
 	return p.printer
 }
@@ -57,13 +153,23 @@ func (p *Plugin[C]) GenDir(path string) string {
 }
 
 func (p *Plugin[C]) Run(args []string) {
+	if len(args) == 2 && args[1] == "--describe" {
+		data, err := json.Marshal(p.Descriptor())
+		ExitOnError(err, "cannot marshal descriptor")
+		_, err = os.Stdout.Write(data)
+		ExitOnError(err, "cannot write descriptor")
+		os.Exit(0)
+	}
 	if len(args) > 1 && args[1] == "--help" {
 		ctx := `This build plugin is usable for both, sole build steps and component build
 steps.`
 		if p.comp {
 			ctx = `This build plugin is usable for component build steps, only.`
 		}
-		fmt.Fprintf(os.Stderr, `Usage: %s <env json> <index> <config>\n
+		fmt.Fprintf(os.Stderr, `Usage: %s <env json> <index> <config>
+       %s --request <file> --response <file>
+       %s --serve
+
 Stdin is used to pass the processing state, if index > 0. The index is the
 index of the component version entry in the component component constructor
 list. The modified state is taken from stdout. Stderr can be used to provide
@@ -72,7 +178,24 @@ regular text output and error messages.
 The config is taken from the plugin config in the Buildfile and uses the
 following fields:
 %s
-`, args[0], ctx, p.usage)
+With the option --describe a JSON descriptor of the plugin is written to
+stdout.
+With protocol version v2 the environment, index, config and state are passed
+with a JSON request file and the modified state is written to a JSON response
+file.
+With the option --serve the plugin runs as server processing several build
+steps. JSON-RPC 2.0 requests are read from stdin and the responses are written
+to stdout, one message per line.
+`, args[0], args[0], args[0], ctx, p.usage)
+		os.Exit(0)
+	}
+	if len(args) == 2 && args[1] == OPT_SERVE {
+		ExitOnError(p.serve(), "server failed")
+		os.Exit(0)
+	}
+	if len(args) == 5 && args[1] == OPT_REQUEST && args[3] == OPT_RESPONSE {
+		p.runV2(args[2], args[4])
+		return
 	}
 	if len(args) != 4 {
 		Error("usage: %s <env> <index> <config> (found %#v)", args[0], args)
@@ -94,17 +217,8 @@ following fields:
 	err = json.Unmarshal(data, &pstate)
 	ExitOnError(err, "cannot unmarshal state")
 
-	if len(pstate.Components) <= int(index) {
-		Error("index %d out of range", index)
-	}
-	if index < 0 {
-		if p.comp {
-			Error("plugin suitable to component build steps, only")
-		}
-		ExitOnError(p.handler.Run(p, &pstate, nil), "plugin failed")
-	} else {
-		ExitOnError(p.handler.Run(p, &pstate, pstate.Components[index]), "plugin failed")
-	}
+	err = p.process(&pstate, int(index))
+	ExitOnError(err, "plugin failed")
 
 	data, err = json.Marshal(pstate)
 	ExitOnError(err, "cannot marshal state")
@@ -112,6 +226,59 @@ following fields:
 	ExitOnError(err, "cannot write output")
 }
 
+// runV2 processes a request of protocol version v2.
+func (p *Plugin[C]) runV2(request, response string) {
+	var resp Response
+	err := p.handleRequest(request, &resp)
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	data, merr := json.Marshal(&resp)
+	ExitOnError(merr, "cannot marshal response")
+	ExitOnError(os.WriteFile(response, data, 0o600), "cannot write response")
+	ExitOnError(err, "plugin failed")
+}
+
+func (p *Plugin[C]) handleRequest(request string, resp *Response) error {
+	data, err := os.ReadFile(request)
+	if err != nil {
+		return fmt.Errorf("cannot read request: %w", err)
+	}
+	var req Request
+	err = json.Unmarshal(data, &req)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal request: %w", err)
+	}
+	if req.Protocol != PROTOCOL_V2 {
+		return fmt.Errorf("unsupported protocol version %q", req.Protocol)
+	}
+	pstate := req.State
+	if pstate == nil {
+		pstate = &state.Descriptor{}
+	}
+	err = p.Process(&req.Environment, req.Config, pstate, req.Index)
+	if err != nil {
+		return err
+	}
+	resp.State = pstate
+	return nil
+}
+
+// process executes the handler for the given state and
+// component index (negative for generic build steps).
+func (p *Plugin[C]) process(pstate *state.Descriptor, index int) error {
+	if len(pstate.Components) <= index {
+		return fmt.Errorf("index %d out of range", index)
+	}
+	if index < 0 {
+		if p.comp {
+			return fmt.Errorf("plugin suitable to component build steps, only")
+		}
+		return p.handler.Run(p, pstate, nil)
+	}
+	return p.handler.Run(p, pstate, pstate.Components[index])
+}
+
 func Error(msg string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, msg+"\n", args...)
 	os.Exit(1)
@@ -0,0 +1,31 @@
+package ppi
+
+import (
+	"encoding/json"
+
+	"github.com/mandelsoft/ocm-build/state"
+)
+
+const (
+	// PROTOCOL_V2 exchanges a request and a response file
+	// instead of command line arguments and stdin/stdout.
+	PROTOCOL_V2 = "v2"
+
+	OPT_REQUEST  = "--request"
+	OPT_RESPONSE = "--response"
+)
+
+// Request is the request envelope of protocol version v2.
+type Request struct {
+	Protocol    string            `json:"protocol"`
+	Environment state.Environment `json:"environment"`
+	Index       int               `json:"index"`
+	Config      json.RawMessage   `json:"config,omitempty"`
+	State       *state.Descriptor `json:"state"`
+}
+
+// Response is the response envelope of protocol version v2.
+type Response struct {
+	State *state.Descriptor `json:"state,omitempty"`
+	Error string            `json:"error,omitempty"`
+}
@@ -0,0 +1,219 @@
+package ppi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mandelsoft/goutils/errors"
+)
+
+// Schema is a (simplified) JSON schema.
+type Schema map[string]interface{}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// SchemaFor generates a JSON schema for the JSON representation
+// of a Go type. Types with custom unmarshalling accept any value.
+// Like for encoding/json, unknown fields of structs are accepted.
+// For a nil type no schema is provided.
+func SchemaFor(t reflect.Type) Schema {
+	if t == nil {
+		return nil
+	}
+	return schemaFor(t, map[reflect.Type]bool{})
+}
+
+func schemaFor(t reflect.Type, visited map[reflect.Type]bool) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshaler) {
+		return Schema{}
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return Schema{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string"}
+		}
+		return Schema{"type": "array", "items": schemaFor(t.Elem(), visited)}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": schemaFor(t.Elem(), visited)}
+	case reflect.Struct:
+		if visited[t] {
+			return Schema{"type": "object"}
+		}
+		visited[t] = true
+		defer delete(visited, t)
+		props := Schema{}
+		addFields(t, props, visited)
+		return Schema{"type": "object", "properties": props}
+	default:
+		return Schema{}
+	}
+}
+
+func addFields(t reflect.Type, props Schema, visited map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if (f.Anonymous && name == "" || opts == "inline") && ft.Kind() == reflect.Struct {
+			addFields(ft, props, visited)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaFor(f.Type, visited)
+	}
+}
+
+// Validate validates a JSON document against a schema.
+func (s Schema) Validate(data []byte) error {
+	if len(s) == 0 || len(data) == 0 {
+		return nil
+	}
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return errors.Wrapf(err, "invalid JSON")
+	}
+	if v == nil {
+		return nil
+	}
+	list := errors.ErrListf("config validation failed")
+	validate(list, s, v, "")
+	return list.Result()
+}
+
+// validate checks a value like encoding/json decodes it: null is accepted
+// for all types and field names are matched case-insensitively.
+func validate(list *errors.ErrorList, s map[string]interface{}, v interface{}, path string) {
+	if v == nil {
+		return
+	}
+	field := path
+	if field == "" {
+		field = "config"
+	}
+	switch s["type"] {
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			list.Add(fmt.Errorf("%s: object expected", field))
+			return
+		}
+		props, _ := asSchema(s["properties"])
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p, _ := lookup(props, k)
+			if ps, ok := asSchema(p); ok {
+				validate(list, ps, m[k], join(path, k))
+				continue
+			}
+			if a, ok := s["additionalProperties"].(bool); ok && !a {
+				list.Add(fmt.Errorf("%s: unknown field", join(path, k)))
+			} else if a, ok := asSchema(s["additionalProperties"]); ok {
+				validate(list, a, m[k], join(path, k))
+			}
+		}
+		if req, ok := s["required"].([]interface{}); ok {
+			for _, r := range req {
+				if _, ok := lookup(m, fmt.Sprint(r)); !ok {
+					list.Add(fmt.Errorf("%s: missing required field", join(path, fmt.Sprint(r))))
+				}
+			}
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			list.Add(fmt.Errorf("%s: array expected", field))
+			return
+		}
+		if is, ok := asSchema(s["items"]); ok {
+			for i, e := range a {
+				validate(list, is, e, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			list.Add(fmt.Errorf("%s: string expected", field))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			list.Add(fmt.Errorf("%s: boolean expected", field))
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			list.Add(fmt.Errorf("%s: number expected", field))
+		}
+	case "integer":
+		if f, ok := v.(float64); !ok || f != float64(int64(f)) {
+			list.Add(fmt.Errorf("%s: integer expected", field))
+		}
+	}
+}
+
+// lookup provides the value for a field name. Like encoding/json,
+// an exact match is preferred over a case-insensitive one.
+func lookup(m map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func asSchema(v interface{}) (map[string]interface{}, bool) {
+	switch s := v.(type) {
+	case Schema:
+		return s, true
+	case map[string]interface{}:
+		return s, true
+	}
+	return nil, false
+}
+
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
@@ -0,0 +1,126 @@
+package ppi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type schemaInner struct {
+	Value int `json:"value"`
+}
+
+type schemaConfig struct {
+	Name     string            `json:"name"`
+	Enabled  bool              `json:"enabled,omitempty"`
+	Ratio    float64           `json:"ratio"`
+	Args     []string          `json:"args"`
+	Env      map[string]string `json:"env"`
+	Inner    *schemaInner      `json:"inner"`
+	Data     []byte            `json:"data"`
+	Timeout  time.Duration     `json:"timeout"`
+	Raw      json.RawMessage   `json:"raw"`
+	Any      interface{}       `json:"any"`
+	Ignored  string            `json:"-"`
+	Untagged string
+	Nested   map[string]schemaInner `json:"nested"`
+	schemaEmbedded
+}
+
+type schemaEmbedded struct {
+	Embedded string `json:"embedded"`
+}
+
+func TestSchemaForNil(t *testing.T) {
+	if s := SchemaFor(nil); s != nil {
+		t.Errorf("expected no schema, found %v", s)
+	}
+	var config interface{}
+	if s := SchemaFor(reflect.TypeOf(&config).Elem()); len(s) != 0 {
+		t.Errorf("expected empty schema, found %v", s)
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := SchemaFor(reflect.TypeOf(schemaConfig{}))
+
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"empty", ``, ""},
+		{"null", `null`, ""},
+		{"valid", `{"name":"a","enabled":true,"ratio":1.5,"args":["x"],"env":{"A":"b"},"inner":{"value":1},"data":"AA==","timeout":5,"raw":[1],"any":{},"Untagged":"u","nested":{"a":{"value":2}},"embedded":"e"}`, ""},
+		{"null fields", `{"name":null,"args":null,"inner":null,"env":{"A":null}}`, ""},
+		{"case-insensitive fields", `{"NAME":"a","Inner":{"VALUE":1},"untagged":"u","Embedded":"e"}`, ""},
+		{"case-insensitive type check", `{"Name":1}`, "config validation failed: Name: string expected"},
+		{"invalid JSON", `{`, "invalid JSON: unexpected end of JSON input"},
+		{"no object", `[]`, "config validation failed: config: object expected"},
+		{"unknown field", `{"other":1}`, ""},
+		{"ignored field", `{"Ignored":"x"}`, ""},
+		{"wrong types", `{"args":[1],"enabled":"x","inner":{"value":1.5},"ratio":"1"}`, "config validation failed: {args[0]: string expected, enabled: boolean expected, inner.value: integer expected, ratio: number expected}"},
+		{"map values", `{"env":{"A":1},"nested":{"a":{"other":1,"value":"x"}}}`, "config validation failed: {env.A: string expected, nested.a.value: integer expected}"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := schema.Validate([]byte(tc.config))
+			if tc.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error %q", tc.err)
+			}
+			if err.Error() != tc.err {
+				t.Errorf("expected error %q, found %q", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestSchemaRequired(t *testing.T) {
+	var schema Schema
+	err := json.Unmarshal([]byte(`{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`), &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{"present", `{"name":"a"}`, true},
+		{"case-insensitive", `{"Name":"a"}`, true},
+		{"null", `{"name":null}`, true},
+		{"missing", `{}`, false},
+		{"additional field", `{"name":"a","other":1}`, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := schema.Validate([]byte(tc.config))
+			if tc.valid != (err == nil) {
+				t.Errorf("unexpected result: %v", err)
+			}
+		})
+	}
+}
+
+func TestSchemaStrict(t *testing.T) {
+	var schema Schema
+	err := json.Unmarshal([]byte(`{"type":"object","properties":{"name":{"type":"string"}},"additionalProperties":false}`), &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate([]byte(`{"name":"a"}`)); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	err = schema.Validate([]byte(`{"name":"a","other":1}`))
+	if err == nil || err.Error() != "config validation failed: other: unknown field" {
+		t.Errorf("unexpected result: %v", err)
+	}
+}
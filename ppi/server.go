@@ -0,0 +1,188 @@
+package ppi
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/mandelsoft/ocm-build/state"
+)
+
+const (
+	// OPT_SERVE starts a plugin in server mode. Requests are read
+	// from stdin and responses are written to stdout as JSON-RPC 2.0
+	// messages, one per line.
+	OPT_SERVE = "--serve"
+
+	JSONRPC_VERSION = "2.0"
+
+	// METHOD_PROCESS executes a build step. The params are a Request
+	// and the result is a Response.
+	METHOD_PROCESS = "process"
+	// METHOD_SHUTDOWN terminates the server.
+	METHOD_SHUTDOWN = "shutdown"
+	// METHOD_EVENT is a notification sent by the plugin for
+	// every emitted event.
+	METHOD_EVENT = "event"
+
+	RPC_PARSE_ERROR      = -32700
+	RPC_INVALID_REQUEST  = -32600
+	RPC_METHOD_NOT_FOUND = -32601
+	RPC_INVALID_PARAMS   = -32602
+	// RPC_PLUGIN_ERROR is used for failing build steps.
+	RPC_PLUGIN_ERROR = -32000
+)
+
+// RPCMessage is a JSON-RPC 2.0 request, notification or response.
+type RPCMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// RPCChannel reads and writes JSON-RPC messages as JSON lines.
+type RPCChannel struct {
+	lock    sync.Mutex
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func NewRPCChannel(in io.Reader, out io.Writer) *RPCChannel {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	return &RPCChannel{scanner: scanner, out: out}
+}
+
+// Read reads the next message. At the end of the input io.EOF
+// is returned.
+func (c *RPCChannel) Read() (*RPCMessage, error) {
+	for c.scanner.Scan() {
+		if len(c.scanner.Bytes()) == 0 {
+			continue
+		}
+		var msg RPCMessage
+		err := json.Unmarshal(c.scanner.Bytes(), &msg)
+		if err != nil {
+			return nil, &RPCError{Code: RPC_PARSE_ERROR, Message: err.Error()}
+		}
+		return &msg, nil
+	}
+	if err := c.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Write writes a message.
+func (c *RPCChannel) Write(msg *RPCMessage) error {
+	msg.JSONRPC = JSONRPC_VERSION
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err = c.out.Write(append(data, '\n'))
+	return err
+}
+
+// Notify writes a notification.
+func (c *RPCChannel) Notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.Write(&RPCMessage{Method: method, Params: data})
+}
+
+// serve runs the plugin in server mode until the shutdown request
+// is received or stdin is closed. Stdout is reserved for the
+// RPC channel, regular output written to stdout is redirected to stderr.
+func (p *Plugin[C]) serve() error {
+	ch := NewRPCChannel(os.Stdin, os.Stdout)
+	os.Stdout = os.Stderr
+	return p.serveChannel(ch)
+}
+
+// serveChannel handles the requests read from the channel until the
+// shutdown request is received or the input is closed.
+func (p *Plugin[C]) serveChannel(ch *RPCChannel) error {
+	p.events = func(ev *Event) error {
+		return ch.Notify(METHOD_EVENT, ev)
+	}
+	defer func() { p.events = nil }()
+
+	for {
+		msg, err := ch.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if rerr, ok := err.(*RPCError); ok {
+				ch.Write(&RPCMessage{Error: rerr})
+				continue
+			}
+			return err
+		}
+		if msg.ID == nil {
+			// notifications are not handled
+			continue
+		}
+		resp := &RPCMessage{ID: msg.ID}
+		switch msg.Method {
+		case METHOD_SHUTDOWN:
+			return ch.Write(resp)
+		case METHOD_PROCESS:
+			result, rerr := p.serveProcess(msg.Params)
+			if rerr != nil {
+				resp.Error = rerr
+			} else {
+				resp.Result, err = json.Marshal(result)
+				if err != nil {
+					resp.Error = &RPCError{Code: RPC_PLUGIN_ERROR, Message: err.Error()}
+				}
+			}
+		default:
+			resp.Error = &RPCError{Code: RPC_METHOD_NOT_FOUND, Message: fmt.Sprintf("unknown method %q", msg.Method)}
+		}
+		err = ch.Write(resp)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Plugin[C]) serveProcess(params json.RawMessage) (*Response, *RPCError) {
+	var req Request
+	err := json.Unmarshal(params, &req)
+	if err != nil {
+		return nil, &RPCError{Code: RPC_INVALID_PARAMS, Message: fmt.Sprintf("cannot unmarshal request: %s", err)}
+	}
+	if req.Protocol != PROTOCOL_V2 {
+		return nil, &RPCError{Code: RPC_INVALID_PARAMS, Message: fmt.Sprintf("unsupported protocol version %q", req.Protocol)}
+	}
+	pstate := req.State
+	if pstate == nil {
+		pstate = &state.Descriptor{}
+	}
+	err = p.Process(&req.Environment, req.Config, pstate, req.Index)
+	if err != nil {
+		return nil, &RPCError{Code: RPC_PLUGIN_ERROR, Message: err.Error()}
+	}
+	return &Response{State: pstate}, nil
+}
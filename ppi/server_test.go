@@ -0,0 +1,256 @@
+package ppi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	common "ocm.software/ocm/api/utils/misc"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/comp"
+
+	"github.com/mandelsoft/ocm-build/state"
+)
+
+func TestRPCChannelRead(t *testing.T) {
+	in := strings.Join([]string{
+		``,
+		`{"jsonrpc":"2.0","id":1,"method":"process","params":{"index":1}}`,
+		``,
+		`{"jsonrpc":"2.0","method":"event"}`,
+		`{invalid`,
+		`{"jsonrpc":"2.0","id":2,"result":{}}`,
+	}, "\n")
+	ch := NewRPCChannel(strings.NewReader(in), io.Discard)
+
+	tests := []struct {
+		name   string
+		id     int64
+		method string
+		code   int
+	}{
+		{"request", 1, METHOD_PROCESS, 0},
+		{"notification", 0, METHOD_EVENT, 0},
+		{"parse error", 0, "", RPC_PARSE_ERROR},
+		{"response", 2, "", 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, err := ch.Read()
+			if tc.code != 0 {
+				rerr, ok := err.(*RPCError)
+				if !ok || rerr.Code != tc.code {
+					t.Fatalf("expected RPC error %d, found %v", tc.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Method != tc.method {
+				t.Errorf("expected method %q, found %q", tc.method, msg.Method)
+			}
+			if (tc.id == 0) != (msg.ID == nil) || (msg.ID != nil && *msg.ID != tc.id) {
+				t.Errorf("unexpected id %v", msg.ID)
+			}
+		})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := ch.Read(); err != io.EOF {
+			t.Errorf("expected EOF, found %v", err)
+		}
+	}
+}
+
+func TestRPCChannelWrite(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	ch := NewRPCChannel(strings.NewReader(""), out)
+
+	id := int64(3)
+	err := ch.Write(&RPCMessage{ID: &id, Error: &RPCError{Code: RPC_PLUGIN_ERROR, Message: "failed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ch.Notify(METHOD_EVENT, &Event{Kind: EVENT_INFO, Message: "line1\nline2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ch.Notify(METHOD_EVENT, func() {})
+	if err == nil {
+		t.Errorf("invalid params not detected")
+	}
+
+	expected := `{"jsonrpc":"2.0","id":3,"error":{"code":-32000,"message":"failed"}}` + "\n" +
+		`{"jsonrpc":"2.0","method":"event","params":{"kind":"info","message":"line1\nline2","time":"0001-01-01T00:00:00Z"}}` + "\n"
+	if out.String() != expected {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	// written messages can be read again
+	rch := NewRPCChannel(out, io.Discard)
+	for i := 0; i < 2; i++ {
+		if _, err := rch.Read(); err != nil {
+			t.Errorf("message %d: %s", i+1, err)
+		}
+	}
+}
+
+type serverConfig struct {
+	Value string `json:"value"`
+}
+
+type serverHandler struct{}
+
+func (h *serverHandler) Run(p *Plugin[serverConfig], pstate *state.Descriptor, _ *comp.ResourceSpec) error {
+	if p.Config().Value == "fail" {
+		return fmt.Errorf("step failed")
+	}
+	p.Info("processing " + p.Config().Value)
+	pstate.State["value"] = p.Config().Value
+	return nil
+}
+
+func request(t *testing.T, id int64, method string, params interface{}) string {
+	msg := &RPCMessage{JSONRPC: JSONRPC_VERSION, Method: method}
+	if id != 0 {
+		msg.ID = &id
+	}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Params = data
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func process(protocol, value string) *Request {
+	return &Request{
+		Protocol:    protocol,
+		Environment: *state.NewEnvironment("/base", "/base/gen"),
+		Index:       -1,
+		Config:      json.RawMessage(fmt.Sprintf(`{"value":%q}`, value)),
+		State:       &state.Descriptor{State: map[string]interface{}{}},
+	}
+}
+
+func TestServe(t *testing.T) {
+	in := strings.Join([]string{
+		request(t, 0, METHOD_EVENT, nil),
+		request(t, 1, METHOD_PROCESS, process(PROTOCOL_V2, "a")),
+		request(t, 2, METHOD_PROCESS, process(PROTOCOL_V2, "fail")),
+		request(t, 3, METHOD_PROCESS, process(PROTOCOL_V1, "a")),
+		request(t, 4, METHOD_PROCESS, "invalid"),
+		request(t, 5, "other", nil),
+		`{invalid`,
+		request(t, 6, METHOD_SHUTDOWN, nil),
+		request(t, 7, METHOD_PROCESS, process(PROTOCOL_V2, "b")),
+	}, "\n")
+	out := bytes.NewBuffer(nil)
+
+	p := NewGenericPlugin[serverConfig](&serverHandler{}).SetPrinter(common.NewPrinter(io.Discard))
+	err := p.serveChannel(NewRPCChannel(strings.NewReader(in), out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []*Event
+	responses := map[int64]*RPCMessage{}
+	var errs []*RPCError
+	rch := NewRPCChannel(out, io.Discard)
+	for {
+		msg, err := rch.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case msg.Method == METHOD_EVENT:
+			var ev Event
+			if err := json.Unmarshal(msg.Params, &ev); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, &ev)
+		case msg.ID != nil:
+			responses[*msg.ID] = msg
+		default:
+			errs = append(errs, msg.Error)
+		}
+	}
+
+	if len(events) != 1 || events[0].Kind != EVENT_INFO || events[0].Message != "processing a" {
+		t.Errorf("unexpected events %v", events)
+	}
+	if len(errs) != 1 || errs[0] == nil || errs[0].Code != RPC_PARSE_ERROR {
+		t.Errorf("expected parse error, found %v", errs)
+	}
+
+	tests := []struct {
+		id   int64
+		code int
+	}{
+		{1, 0},
+		{2, RPC_PLUGIN_ERROR},
+		{3, RPC_INVALID_PARAMS},
+		{4, RPC_INVALID_PARAMS},
+		{5, RPC_METHOD_NOT_FOUND},
+		{6, 0},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("request %d", tc.id), func(t *testing.T) {
+			resp := responses[tc.id]
+			if resp == nil {
+				t.Fatalf("no response")
+			}
+			if tc.code != 0 {
+				if resp.Error == nil || resp.Error.Code != tc.code {
+					t.Errorf("expected error %d, found %v", tc.code, resp.Error)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Errorf("unexpected error %s", resp.Error)
+			}
+		})
+	}
+	if len(responses) != len(tests) {
+		t.Errorf("expected %d responses, found %d", len(tests), len(responses))
+	}
+
+	var result Response
+	err = json.Unmarshal(responses[1].Result, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.State == nil || result.State.State["value"] != "a" {
+		t.Errorf("unexpected state %v", result.State)
+	}
+	if responses[2].Error.Message != "step failed" {
+		t.Errorf("unexpected error message %q", responses[2].Error.Message)
+	}
+}
+
+func TestServeEOF(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	p := NewGenericPlugin[serverConfig](&serverHandler{}).SetPrinter(common.NewPrinter(io.Discard))
+	err := p.serveChannel(NewRPCChannel(strings.NewReader("\n"), out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	if p.events != nil {
+		t.Errorf("event handler not reset")
+	}
+}
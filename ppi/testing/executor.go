@@ -0,0 +1,88 @@
+package testing
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+	"github.com/mandelsoft/goutils/errors"
+
+	"github.com/mandelsoft/ocm-build/ppi"
+)
+
+// CommandHandler fakes the execution of a command.
+type CommandHandler func(cmd *exec.Cmd) error
+
+// FakeExecutor records executed commands instead of running them.
+// Handlers can be registered per command name to simulate results.
+type FakeExecutor struct {
+	lock     sync.Mutex
+	commands [][]string
+	handlers map[string]CommandHandler
+}
+
+var _ ppi.Executor = (*FakeExecutor)(nil)
+
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{handlers: map[string]CommandHandler{}}
+}
+
+// Handle registers a handler for a command name (the base name
+// of the executable).
+func (f *FakeExecutor) Handle(name string, h CommandHandler) *FakeExecutor {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.handlers[name] = h
+	return f
+}
+
+func (f *FakeExecutor) Execute(cmd *exec.Cmd) error {
+	f.lock.Lock()
+	f.commands = append(f.commands, append([]string{}, cmd.Args...))
+	h := f.handlers[filepath.Base(cmd.Path)]
+	if h == nil && len(cmd.Args) > 0 {
+		h = f.handlers[filepath.Base(cmd.Args[0])]
+	}
+	f.lock.Unlock()
+
+	if h == nil {
+		return nil
+	}
+	return h(cmd)
+}
+
+// Commands provides the arguments of all executed commands.
+func (f *FakeExecutor) Commands() [][]string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([][]string{}, f.commands...)
+}
+
+// WriteOptionFile provides a handler creating the file given as value
+// of a command line option (for example -o for go build).
+func WriteOptionFile(option string, data []byte) CommandHandler {
+	return func(cmd *exec.Cmd) error {
+		for i, a := range cmd.Args {
+			if a == option && i+1 < len(cmd.Args) {
+				path := cmd.Args[i+1]
+				if cmd.Dir != "" && !filepath.IsAbs(path) {
+					path = filepath.Join(cmd.Dir, path)
+				}
+				err := os.MkdirAll(filepath.Dir(path), 0o755)
+				if err != nil {
+					return err
+				}
+				return os.WriteFile(path, data, 0o755)
+			}
+		}
+		return errors.Newf("option %s not found", option)
+	}
+}
+
+// Fail provides a handler failing with the given error.
+func Fail(err error) CommandHandler {
+	return func(cmd *exec.Cmd) error {
+		return err
+	}
+}
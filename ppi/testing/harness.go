@@ -0,0 +1,176 @@
+// Package testing provides a test harness to execute ppi handlers
+// in-process without running a plugin executable.
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"os"
+
+	"github.com/mandelsoft/filepath/pkg/filepath"
+	"github.com/mandelsoft/goutils/errors"
+	"ocm.software/ocm/api/ocm/compdesc"
+	metav1 "ocm.software/ocm/api/ocm/compdesc/meta/v1"
+	"ocm.software/ocm/api/ocm/compdesc/versions/ocm.software/v3alpha1"
+	common "ocm.software/ocm/api/utils/misc"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/comp"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/rscs"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/srcs"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+	"github.com/mandelsoft/ocm-build/ppi"
+	"github.com/mandelsoft/ocm-build/state"
+)
+
+// Harness executes the handler of a plugin in-process with a synthetic
+// environment located in a temporary directory. External commands are
+// passed to a FakeExecutor.
+type Harness[C any] struct {
+	Plugin   *ppi.Plugin[C]
+	Executor *FakeExecutor
+	Env      *state.Environment
+	State    *state.Descriptor
+	// Output captures the regular output of the plugin.
+	Output *bytes.Buffer
+
+	dir string
+}
+
+// New creates a harness for a plugin. The temporary directories must be
+// removed with Cleanup.
+func New[C any](p *ppi.Plugin[C]) (*Harness[C], error) {
+	dir, err := os.MkdirTemp("", "ppi-test-*")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create temp dir")
+	}
+	env := state.NewEnvironment(filepath.Join(dir, "base"), filepath.Join(dir, "gen"))
+	for _, d := range []string{env.Directory, env.GenDir} {
+		err = os.MkdirAll(d, 0o755)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	out := bytes.NewBuffer(nil)
+	exec := NewFakeExecutor()
+	p.SetExecutor(exec).SetPrinter(common.NewPrinter(out))
+	return &Harness[C]{
+		Plugin:   p,
+		Executor: exec,
+		Env:      env,
+		State:    state.New(&buildfile.Descriptor{}),
+		Output:   out,
+		dir:      dir,
+	}, nil
+}
+
+// Cleanup removes the temporary directories.
+func (h *Harness[C]) Cleanup() error {
+	return os.RemoveAll(h.dir)
+}
+
+// BaseDir provides the directory used as location of the build file.
+func (h *Harness[C]) BaseDir() string {
+	return h.Env.Directory
+}
+
+// GenDir provides the generation directory of the step.
+func (h *Harness[C]) GenDir() string {
+	return h.Env.GenDir
+}
+
+// WriteFile creates a file relative to the base directory.
+func (h *Harness[C]) WriteFile(path string, data []byte) error {
+	path = h.Env.Path(path)
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// AddComponent adds a component to the state and provides its index.
+func (h *Harness[C]) AddComponent(name, version string) int {
+	h.State.Components = append(h.State.Components, &comp.ResourceSpec{
+		Meta: compdesc.Metadata{
+			ConfiguredVersion: v3alpha1.SchemaVersion,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:    name,
+			Version: version,
+		},
+	})
+	return len(h.State.Components) - 1
+}
+
+// Run executes the handler for the component with the given index
+// (negative for a generic build step). The config is either a JSON
+// document ([]byte, json.RawMessage or string) or any value marshalled
+// to JSON.
+func (h *Harness[C]) Run(index int, config interface{}) error {
+	var data []byte
+	switch c := config.(type) {
+	case nil:
+	case []byte:
+		data = c
+	case json.RawMessage:
+		data = c
+	case string:
+		data = []byte(c)
+	default:
+		var err error
+		data, err = json.Marshal(c)
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal config")
+		}
+	}
+	return h.Plugin.Process(h.Env, data, h.State, index)
+}
+
+// Component provides the component with the given index.
+func (h *Harness[C]) Component(index int) *comp.ResourceSpec {
+	if index < 0 || index >= len(h.State.Components) {
+		return nil
+	}
+	return h.State.Components[index]
+}
+
+// Resources provides the resources of the component with the given index.
+func (h *Harness[C]) Resources(index int) []*rscs.ResourceSpec {
+	if c := h.Component(index); c != nil {
+		return c.Resources
+	}
+	return nil
+}
+
+// Sources provides the sources of the component with the given index.
+func (h *Harness[C]) Sources(index int) []*srcs.ResourceSpec {
+	if c := h.Component(index); c != nil {
+		return c.Sources
+	}
+	return nil
+}
+
+// Resource provides the first resource with the given name and extra
+// identity of the component with the given index.
+func (h *Harness[C]) Resource(index int, name string, extra ...string) *rscs.ResourceSpec {
+	id := metav1.NewExtraIdentity(extra...)
+	for _, r := range h.Resources(index) {
+		if r.Name != name {
+			continue
+		}
+		if len(id) > 0 && !maps.Equal(r.ExtraIdentity, id) {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+// FileInput provides the file path of a resource described by
+// a file input.
+func FileInput(r *rscs.ResourceSpec) (string, bool) {
+	return state.FileInput(r)
+}
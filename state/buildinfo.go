@@ -0,0 +1,114 @@
+package state
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	metav1 "ocm.software/ocm/api/ocm/compdesc/meta/v1"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/srcs"
+
+	"github.com/mandelsoft/ocm-build/utils"
+)
+
+const (
+	LABEL_COMMIT       = "ocm.software/build/commit"
+	LABEL_REF          = "ocm.software/build/ref"
+	LABEL_BUILD_TIME   = "ocm.software/build/time"
+	LABEL_BUILDER_HOST = "ocm.software/build/host"
+	LABEL_DIRTY        = "ocm.software/build/dirty"
+
+	SOURCE_NAME = "source"
+	SOURCE_TYPE = "git"
+
+	ACCESS_GITHUB = "gitHub"
+	ACCESS_GIT    = "git"
+)
+
+// BuildInfo describes the build metadata automatically
+// added to components.
+type BuildInfo struct {
+	Git  *utils.GitInfo
+	Time time.Time
+	Host string
+}
+
+// Labels provides the build metadata labels.
+func (i *BuildInfo) Labels() (metav1.Labels, error) {
+	var labels metav1.Labels
+
+	add := func(name string, value interface{}) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		labels = append(labels, metav1.Label{Name: name, Value: data})
+		return nil
+	}
+
+	if i.Git != nil {
+		if err := add(LABEL_COMMIT, i.Git.Commit); err != nil {
+			return nil, err
+		}
+		if err := add(LABEL_DIRTY, i.Git.Dirty); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(LABEL_BUILD_TIME, i.Time.UTC().Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+	if i.Host != "" {
+		if err := add(LABEL_BUILDER_HOST, i.Host); err != nil {
+			return nil, err
+		}
+	}
+	return labels, nil
+}
+
+// GitAccess provides the access specification for a git commit.
+// The gitHub access type is used for repositories hosted on github.com,
+// the generic git access type for all other hosts.
+func GitAccess(git *utils.GitInfo) map[string]interface{} {
+	if u, err := url.Parse(git.Repository); err == nil && (u.Hostname() == "github.com" || u.Hostname() == "www.github.com") {
+		return map[string]interface{}{
+			"type":    ACCESS_GITHUB,
+			"repoUrl": git.Repository,
+			"commit":  git.Commit,
+		}
+	}
+	access := map[string]interface{}{
+		"type":       ACCESS_GIT,
+		"repository": git.Repository,
+		"commit":     git.Commit,
+	}
+	if git.Ref != "" {
+		access["ref"] = git.Ref
+	}
+	return access
+}
+
+// Source provides a git source entry for the working tree, if
+// the build is executed in a git working tree with a remote.
+func (i *BuildInfo) Source() (*srcs.ResourceSpec, error) {
+	if i.Git == nil || i.Git.Repository == "" {
+		return nil, nil
+	}
+	spec := map[string]interface{}{
+		"name":   SOURCE_NAME,
+		"type":   SOURCE_TYPE,
+		"access": GitAccess(i.Git),
+	}
+	if i.Git.Ref != "" {
+		spec["labels"] = []map[string]interface{}{{"name": LABEL_REF, "value": i.Git.Ref}}
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var src srcs.ResourceSpec
+	err = json.Unmarshal(data, &src)
+	if err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
@@ -0,0 +1,39 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/mandelsoft/goutils/general"
+
+	"github.com/mandelsoft/ocm-build/buildfile"
+)
+
+// GenerateComponent requests the build of an additional component. It is
+// used by generator plugins to add components not described by the build
+// file. The engine builds the generated components with the given build
+// steps after the components described by the build file.
+func (d *Descriptor) GenerateComponent(c *buildfile.Component) error {
+	if c.Name == "" {
+		return fmt.Errorf("component name required")
+	}
+	vers := d.version(c.Version)
+	for _, e := range d.Components {
+		if e.Name == c.Name && e.Version == vers {
+			return fmt.Errorf("component %s:%s already exists", c.Name, vers)
+		}
+	}
+	for _, e := range d.Generated {
+		if e.Name == c.Name && d.version(e.Version) == vers {
+			return fmt.Errorf("component %s:%s already generated", c.Name, vers)
+		}
+	}
+	d.Generated = append(d.Generated, *c)
+	return nil
+}
+
+func (d *Descriptor) version(v string) string {
+	if d.BuildFile == nil {
+		return v
+	}
+	return general.OptionalDefaulted(d.BuildFile.Version, v)
+}
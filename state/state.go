@@ -1,13 +1,18 @@
 package state
 
 import (
+	"encoding/json"
 	"slices"
+	"strings"
 
+	"github.com/mandelsoft/goutils/errors"
 	"github.com/mandelsoft/goutils/general"
 	"ocm.software/ocm/api/ocm/compdesc"
 	metav1 "ocm.software/ocm/api/ocm/compdesc/meta/v1"
 	"ocm.software/ocm/api/ocm/compdesc/versions/ocm.software/v3alpha1"
 	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/comp"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/addhdlrs/rscs"
+	"ocm.software/ocm/cmds/ocm/commands/ocmcmds/common/inputs/types/file"
 
 	"github.com/mandelsoft/ocm-build/buildfile"
 )
@@ -21,6 +26,9 @@ type Descriptor struct {
 	State      map[string]interface{} `json:"state,omitempty"`
 	BuildFile  *buildfile.Descriptor  `json:"buildfile,omitempty"`
 	Components []*comp.ResourceSpec   `json:"components,omitempty"`
+	// Generated lists the components requested by generator plugins,
+	// which are not yet built.
+	Generated []buildfile.Component `json:"generated,omitempty"`
 }
 
 func New(buildfile *buildfile.Descriptor) *Descriptor {
@@ -30,7 +38,11 @@ func New(buildfile *buildfile.Descriptor) *Descriptor {
 	}
 }
 
-func (d *Descriptor) AddComponent(c *buildfile.Component) *comp.ResourceSpec {
+// AddComponent adds a constructor for the given component. If build
+// information is given, it is used to add a git source entry and/or
+// build metadata labels according to the buildInfo settings of the
+// build file and the component.
+func (d *Descriptor) AddComponent(c *buildfile.Component, info ...*BuildInfo) (*comp.ResourceSpec, error) {
 
 	constructor := &comp.ResourceSpec{
 		Meta: compdesc.Metadata{
@@ -44,8 +56,28 @@ func (d *Descriptor) AddComponent(c *buildfile.Component) *comp.ResourceSpec {
 		},
 	}
 
+	if bi := general.Optional(info...); bi != nil {
+		settings := d.BuildFile.BuildInfo.Merge(c.BuildInfo)
+		if settings.AddLabels() {
+			labels, err := bi.Labels()
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot create build labels")
+			}
+			constructor.Labels = MergeLabels(labels, constructor.Labels)
+		}
+		if settings.AddSource() {
+			src, err := bi.Source()
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot create git source")
+			}
+			if src != nil {
+				constructor.Sources = append(constructor.Sources, src)
+			}
+		}
+	}
+
 	d.Components = append(d.Components, constructor)
-	return constructor
+	return constructor, nil
 }
 
 func MergeProvider(a, b *metav1.Provider) *metav1.Provider {
@@ -109,3 +141,26 @@ func MergeElements[E Element](a, b []E, mod ...func(e E)) []E {
 	}
 	return a
 }
+
+// FileInput provides the file path of a resource described
+// by a file input.
+func FileInput(r *rscs.ResourceSpec) (string, bool) {
+	if r.Input == nil {
+		return "", false
+	}
+	data, err := json.Marshal(r.Input)
+	if err != nil {
+		return "", false
+	}
+	var spec struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+	}
+	if json.Unmarshal(data, &spec) != nil || spec.Path == "" {
+		return "", false
+	}
+	if spec.Type != file.TYPE && !strings.HasPrefix(spec.Type, file.TYPE+"/") {
+		return "", false
+	}
+	return spec.Path, true
+}
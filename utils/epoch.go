@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mandelsoft/goutils/errors"
+)
+
+const ENV_SOURCE_DATE_EPOCH = "SOURCE_DATE_EPOCH"
+
+// SourceDateEpoch provides the timestamp given by the environment
+// variable SOURCE_DATE_EPOCH. If it is not set, the given default
+// is returned.
+func SourceDateEpoch(def time.Time) (time.Time, error) {
+	s := os.Getenv(ENV_SOURCE_DATE_EPOCH)
+	if s == "" {
+		return def.UTC(), nil
+	}
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "invalid %s", ENV_SOURCE_DATE_EPOCH)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/mandelsoft/goutils/errors"
+)
+
+// GitInfo describes the state of a git working tree.
+type GitInfo struct {
+	Repository string `json:"repository,omitempty"`
+	Commit     string `json:"commit"`
+	Ref        string `json:"ref,omitempty"`
+	Dirty      bool   `json:"dirty,omitempty"`
+}
+
+// GetGitInfo determines the git information for the working tree containing
+// the given directory.
+func GetGitInfo(dir string) (*GitInfo, error) {
+	commit, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot determine git commit")
+	}
+	remote, _ := git(dir, "config", "--get", "remote.origin.url")
+	ref, err := git(dir, "symbolic-ref", "-q", "HEAD")
+	if err != nil {
+		ref, err = git(dir, "describe", "--tags", "--exact-match", "HEAD")
+		if err == nil {
+			ref = "refs/tags/" + ref
+		}
+	}
+	status, err := git(dir, "status", "--porcelain")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot determine git status")
+	}
+	return &GitInfo{
+		Repository: NormalizeGitURL(remote),
+		Commit:     commit,
+		Ref:        ref,
+		Dirty:      status != "",
+	}, nil
+}
+
+// NormalizeGitURL maps scp-like git URLs ([user@]host:path) to
+// https URLs and strips a trailing .git. Credentials (user info)
+// are removed, they must never be published.
+func NormalizeGitURL(s string) string {
+	if i := strings.Index(s, "@"); i >= 0 && !strings.Contains(s, "://") {
+		if j := strings.Index(s[i:], ":"); j > 0 {
+			s = "https://" + s[i+1:i+j] + "/" + strings.TrimPrefix(s[i+j+1:], "/")
+		}
+	}
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		u.User = nil
+		s = u.String()
+	}
+	return strings.TrimSuffix(s, ".git")
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		if e, ok := err.(*exec.ExitError); ok && len(e.Stderr) > 0 {
+			return "", errors.Newf("%s", strings.TrimSpace(string(e.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}